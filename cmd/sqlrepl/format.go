@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+type formatter struct {
+	name  string
+	write func(w io.Writer, rs *sqldb.ResultSet)
+}
+
+var formatters = map[string]formatter{
+	"table": {name: "table", write: writeTable},
+	"csv":   {name: "csv", write: writeCSV},
+	"json":  {name: "json", write: writeJSON},
+}
+
+// writeTable prints rs as an ASCII table with aligned columns.
+func writeTable(w io.Writer, rs *sqldb.ResultSet) {
+	widths := make([]int, len(rs.Columns))
+	cells := make([][]string, 0, len(rs.Rows))
+	for i, col := range rs.Columns {
+		widths[i] = len(col)
+	}
+	for _, row := range rs.Rows {
+		line := make([]string, len(rs.Columns))
+		for i, col := range rs.Columns {
+			line[i] = formatValue(row[col], "NULL")
+			widths[i] = max(widths[i], len([]rune(line[i])))
+		}
+		cells = append(cells, line)
+	}
+
+	border := "+"
+	for _, width := range widths {
+		border += strings.Repeat("-", width+2) + "+"
+	}
+	printLine := func(values []string) {
+		fmt.Fprint(w, "|")
+		for i, val := range values {
+			fmt.Fprintf(w, " %s%s |", val, strings.Repeat(" ", widths[i]-len([]rune(val))))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, border)
+	printLine(rs.Columns)
+	fmt.Fprintln(w, border)
+	for _, line := range cells {
+		printLine(line)
+	}
+	if len(cells) > 0 {
+		fmt.Fprintln(w, border)
+	}
+	fmt.Fprintf(w, "(%d row(s))\n", len(rs.Rows))
+	if rs.Cursor != "" {
+		fmt.Fprintf(w, "next page cursor: %s\n", rs.Cursor)
+	}
+}
+
+// writeCSV prints rs as CSV with a header line; NULL is an empty field.
+func writeCSV(w io.Writer, rs *sqldb.ResultSet) {
+	cw := csv.NewWriter(w)
+	cw.Write(rs.Columns)
+	for _, row := range rs.Rows {
+		record := make([]string, len(rs.Columns))
+		for i, col := range rs.Columns {
+			record[i] = formatValue(row[col], "")
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+// writeJSON prints rs as one JSON object per row, keeping the column order.
+func writeJSON(w io.Writer, rs *sqldb.ResultSet) {
+	for _, row := range rs.Rows {
+		var sb strings.Builder
+		sb.WriteString("{")
+		for i, col := range rs.Columns {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			key, _ := json.Marshal(col)
+			val, err := json.Marshal(row[col])
+			if err != nil {
+				val, _ = json.Marshal(fmt.Sprint(row[col]))
+			}
+			sb.Write(key)
+			sb.WriteString(":")
+			sb.Write(val)
+		}
+		sb.WriteString("}")
+		fmt.Fprintln(w, sb.String())
+	}
+}
+
+func formatValue(val any, null string) string {
+	switch v := val.(type) {
+	case nil:
+		return null
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case json.RawMessage:
+		return string(v)
+	}
+	return fmt.Sprint(val)
+}
+
+// describe lists the columns of a table with their constraints and indexes.
+func describe(table *sqldb.Table) *sqldb.ResultSet {
+	indexes := make(map[string]sqldb.IndexKind)
+	for _, idx := range table.Indexes() {
+		indexes[idx.Column] = idx.Kind
+	}
+	rs := &sqldb.ResultSet{Columns: []string{"column", "type", "constraints", "index"}}
+	for _, col := range table.Columns {
+		rs.Rows = append(rs.Rows, map[string]any{
+			"column":      col.Name,
+			"type":        string(col.Type),
+			"constraints": describeConstraints(col.Constraints),
+			"index":       string(indexes[col.Name]),
+		})
+	}
+	// table checks span several columns, so they get rows of their own
+	for _, check := range table.Checks {
+		rs.Rows = append(rs.Rows, map[string]any{"column": "", "type": "", "constraints": "CHECK " + check.Name, "index": ""})
+	}
+	return rs
+}
+
+func describeConstraints(cc sqldb.ColumnConstraint) string {
+	var parts []string
+	switch {
+	case cc.PrimaryKey:
+		parts = append(parts, "PRIMARY KEY")
+	case cc.Unique && cc.Required:
+		parts = append(parts, "NOT NULL", "UNIQUE")
+	case cc.Unique:
+		parts = append(parts, "UNIQUE")
+	case cc.Required:
+		parts = append(parts, "NOT NULL")
+	}
+	if cc.MinLength != nil {
+		parts = append(parts, fmt.Sprintf("length >= %d", *cc.MinLength))
+	}
+	if cc.MaxLength != nil {
+		parts = append(parts, fmt.Sprintf("length <= %d", *cc.MaxLength))
+	}
+	if cc.MinValue != nil {
+		parts = append(parts, fmt.Sprintf(">= %d", *cc.MinValue))
+	}
+	if cc.MaxValue != nil {
+		parts = append(parts, fmt.Sprintf("<= %d", *cc.MaxValue))
+	}
+	if cc.Pattern != nil {
+		parts = append(parts, fmt.Sprintf("~ '%s'", cc.Pattern))
+	}
+	if len(cc.Enum) > 0 {
+		values := make([]string, len(cc.Enum))
+		for i, val := range cc.Enum {
+			values[i] = formatValue(val, "NULL")
+		}
+		parts = append(parts, "IN ("+strings.Join(values, ", ")+")")
+	}
+	if fk := cc.References; fk != nil {
+		parts = append(parts, fmt.Sprintf("REFERENCES %s ON DELETE %s", fk.Table, fk.OnDelete))
+	}
+	switch {
+	case cc.AutoIncrement:
+		parts = append(parts, "AUTO_INCREMENT")
+	case cc.Default != nil:
+		parts = append(parts, "DEFAULT "+formatValue(cc.Default, "NULL"))
+	case cc.DefaultFunc != nil:
+		parts = append(parts, "DEFAULT (function)")
+	case cc.Computed != nil:
+		parts = append(parts, "COMPUTED")
+	}
+	for _, check := range cc.Checks {
+		parts = append(parts, "CHECK "+check.Name)
+	}
+	return strings.Join(parts, ", ")
+}
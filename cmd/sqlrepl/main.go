@@ -0,0 +1,259 @@
+// Command sqlrepl is an interactive SQL shell for sqldb.
+//
+// Statements end with a semicolon and may span several lines. Lines starting with a
+// backslash are meta-commands, see \?.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+const help = `Statements end with ';' and may span lines. BEGIN, COMMIT and ROLLBACK manage a transaction.
+  \dt                    list tables
+  \d TABLE               describe a table
+  \format table|csv|json set the output format of query results
+  \?                     show this help
+  \q                     quit
+`
+
+func main() {
+	dir := flag.String("db", "", "database directory, created if missing; in-memory when empty")
+	format := flag.String("format", "table", "output format: table, csv or json")
+	flag.Parse()
+
+	db := sqldb.NewDatabase()
+	if *dir != "" {
+		var err error
+		if db, err = sqldb.Open(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	r := &repl{db: db, out: os.Stdout, interactive: isTerminal(os.Stdin)}
+	if err := r.setFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	r.run(os.Stdin)
+	if r.tx != nil {
+		r.tx.Rollback()
+	}
+	if err := db.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type repl struct {
+	db          *sqldb.Database
+	tx          *sqldb.Tx // open transaction, nil outside BEGIN ... COMMIT
+	out         io.Writer
+	format      formatter
+	interactive bool
+}
+
+// run reads statements and meta-commands from in until it is exhausted or \q.
+func (r *repl) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var pending string
+	for {
+		r.prompt(pending)
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Text()
+		if strings.TrimSpace(pending) == "" && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			if !r.meta(strings.Fields(strings.TrimSpace(line))) {
+				return
+			}
+			pending = ""
+			continue
+		}
+		var stmts []string
+		stmts, pending = splitStatements(pending + line + "\n")
+		for _, stmt := range stmts {
+			r.execute(stmt)
+		}
+	}
+	if strings.TrimSpace(pending) != "" {
+		// the last statement may omit its semicolon
+		r.execute(pending)
+	}
+}
+
+func (r *repl) prompt(pending string) {
+	if !r.interactive {
+		return
+	}
+	switch {
+	case strings.TrimSpace(pending) != "":
+		fmt.Fprint(r.out, "   ...> ")
+	case r.tx != nil:
+		fmt.Fprint(r.out, "sqldb*> ")
+	default:
+		fmt.Fprint(r.out, "sqldb> ")
+	}
+}
+
+// meta runs a backslash command and reports whether the shell should go on.
+func (r *repl) meta(args []string) bool {
+	switch args[0] {
+	case `\q`:
+		return false
+	case `\?`:
+		fmt.Fprint(r.out, help)
+	case `\dt`:
+		for _, name := range r.db.Tables() {
+			fmt.Fprintln(r.out, name)
+		}
+	case `\d`:
+		if len(args) != 2 {
+			r.error(fmt.Errorf(`usage: \d TABLE`))
+			break
+		}
+		table, err := r.db.GetTable(args[1])
+		if err != nil {
+			r.error(err)
+			break
+		}
+		r.format.write(r.out, describe(table))
+	case `\format`:
+		if len(args) != 2 {
+			fmt.Fprintf(r.out, "output format is %s\n", r.format.name)
+			break
+		}
+		if err := r.setFormat(args[1]); err != nil {
+			r.error(err)
+		}
+	default:
+		r.error(fmt.Errorf(`unknown command %s, try \?`, args[0]))
+	}
+	return true
+}
+
+func (r *repl) setFormat(name string) error {
+	f, ok := formatters[name]
+	if !ok {
+		return fmt.Errorf("unknown format %s, expected table, csv or json", name)
+	}
+	r.format = f
+	return nil
+}
+
+// execute runs one statement, printing its result or error.
+func (r *repl) execute(text string) {
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "BEGIN", "BEGIN TRANSACTION":
+		if r.tx != nil {
+			r.error(fmt.Errorf("a transaction is already open"))
+			return
+		}
+		r.tx = r.db.Begin()
+		return
+	case "COMMIT", "ROLLBACK":
+		if r.tx == nil {
+			r.error(fmt.Errorf("no transaction is open"))
+			return
+		}
+		tx := r.tx
+		r.tx = nil
+		if strings.EqualFold(strings.TrimSpace(text), "COMMIT") {
+			r.error(tx.Commit())
+		} else {
+			r.error(tx.Rollback())
+		}
+		return
+	}
+
+	stmts, err := sqldb.Parse(text)
+	if err != nil {
+		r.error(err)
+		return
+	}
+	if len(stmts) == 0 {
+		return
+	}
+	switch stmts[0].(type) {
+	case *sqldb.SelectStmt, *sqldb.ExplainStmt:
+		var rs *sqldb.ResultSet
+		if r.tx != nil {
+			rs, err = r.tx.Query(text)
+		} else {
+			rs, err = r.db.Query(text)
+		}
+		if err != nil {
+			r.error(err)
+			return
+		}
+		r.format.write(r.out, rs)
+		return
+	}
+
+	var res sqldb.Result
+	if r.tx != nil {
+		res, err = r.tx.Exec(text)
+	} else {
+		res, err = r.db.Exec(text)
+	}
+	if err != nil {
+		r.error(err)
+		return
+	}
+	switch stmts[0].(type) {
+	case *sqldb.InsertStmt, *sqldb.UpdateStmt, *sqldb.DeleteStmt:
+		fmt.Fprintf(r.out, "%d row(s) affected\n", res.RowsAffected)
+	default:
+		fmt.Fprintln(r.out, "OK")
+	}
+}
+
+func (r *repl) error(err error) {
+	if err != nil {
+		fmt.Fprintf(r.out, "ERROR: %v\n", err)
+	}
+}
+
+// splitStatements cuts the complete statements, those ending with a semicolon outside
+// string literals and comments, off the front of src and returns them with the rest.
+func splitStatements(src string) ([]string, string) {
+	var stmts []string
+	start := 0
+	inString, inComment := false, false
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case inComment:
+			inComment = ch != '\n'
+		case inString:
+			// a doubled quote escapes itself and just toggles twice
+			inString = ch != '\''
+		case ch == '\'':
+			inString = true
+		case ch == '-' && i+1 < len(src) && src[i+1] == '-':
+			inComment = true
+		case ch == ';':
+			if stmt := strings.TrimSpace(src[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		}
+	}
+	rest := src[start:]
+	if strings.TrimSpace(rest) == "" {
+		rest = ""
+	}
+	return stmts, rest
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		src   string
+		stmts []string
+		rest  string
+	}{
+		{"SELECT 1; SELECT 2;\n", []string{"SELECT 1", "SELECT 2"}, ""},
+		{"SELECT * FROM t\nWHERE a = 1", nil, "SELECT * FROM t\nWHERE a = 1"},
+		{"INSERT INTO t VALUES ('a;b'); SELECT", []string{"INSERT INTO t VALUES ('a;b')"}, " SELECT"},
+		{"INSERT INTO t VALUES ('it''s;'); -- done; really\n", []string{"INSERT INTO t VALUES ('it''s;')"}, " -- done; really\n"},
+		{";;  \n", nil, ""},
+	}
+	for _, tt := range tests {
+		stmts, rest := splitStatements(tt.src)
+		if !reflect.DeepEqual(stmts, tt.stmts) || rest != tt.rest {
+			t.Errorf("splitStatements(%q) = %q, %q, want %q, %q", tt.src, stmts, rest, tt.stmts, tt.rest)
+		}
+	}
+}
+
+// runScript feeds script to a repl over a new in-memory database and returns its output.
+func runScript(t *testing.T, script string) string {
+	t.Helper()
+	var out strings.Builder
+	r := &repl{db: sqldb.NewDatabase(), out: &out}
+	if err := r.setFormat("table"); err != nil {
+		t.Fatal(err)
+	}
+	r.run(strings.NewReader(script))
+	return out.String()
+}
+
+func TestReplScript(t *testing.T) {
+	got := runScript(t, `CREATE TABLE users (id INT PRIMARY KEY, name STRING NOT NULL);
+INSERT INTO users (id, name)
+VALUES (1, 'ada'), (2, NULL);
+INSERT INTO users (id, name) VALUES (1, 'ada'), (2, 'linus');
+BEGIN;
+DELETE FROM users WHERE id = 1;
+ROLLBACK;
+\format csv
+SELECT id, name FROM users ORDER BY id;
+\format json
+SELECT name FROM users WHERE id = 2
+`)
+	want := `OK
+ERROR: users.name violates not null constraint: value is required
+2 row(s) affected
+1 row(s) affected
+id,name
+1,ada
+2,linus
+{"name":"linus"}
+`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReplMetaCommands(t *testing.T) {
+	got := runScript(t, `CREATE TABLE users (id INT PRIMARY KEY, name STRING UNIQUE);
+\dt
+\d users
+\d nope
+\format xml
+COMMIT;
+\q
+SELECT * FROM users;
+`)
+	want := `OK
+users
++--------+--------+-------------+-------+
+| column | type   | constraints | index |
++--------+--------+-------------+-------+
+| id     | int    | PRIMARY KEY | hash  |
+| name   | string | UNIQUE      | hash  |
++--------+--------+-------------+-------+
+(2 row(s))
+ERROR: table not found: nope
+ERROR: unknown format xml, expected table, csv or json
+ERROR: no transaction is open
+`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+// Command sqlserver serves a sqldb database over a TCP or Unix socket, see package wire.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+	"github.com/avalokitasharma/lld/sql-db/wire"
+)
+
+func main() {
+	network := flag.String("network", "tcp", "tcp or unix")
+	address := flag.String("listen", "127.0.0.1:5433", "address, or socket path for unix")
+	dir := flag.String("db", "", "database directory, created if missing; in-memory when empty")
+	flag.Parse()
+
+	db := sqldb.NewDatabase()
+	if *dir != "" {
+		var err error
+		if db, err = sqldb.Open(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	srv := wire.NewServer(db)
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		srv.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "serving on %s %s\n", *network, *address)
+	if err := srv.ListenAndServe(*network, *address); err != wire.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := db.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
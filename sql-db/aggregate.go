@@ -0,0 +1,250 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type AggregateFunc string
+
+const (
+	AggCount AggregateFunc = "COUNT"
+	AggSum   AggregateFunc = "SUM"
+	AggMin   AggregateFunc = "MIN"
+	AggMax   AggregateFunc = "MAX"
+	AggAvg   AggregateFunc = "AVG"
+)
+
+// Aggregate computes one value over each group of rows. COUNT of column "*" counts
+// rows; every other aggregate skips NULLs and is NULL for a group without values.
+// COUNT, and SUM of an int column, are int64; a SUM that doesn't fit one is an error.
+type Aggregate struct {
+	Func   AggregateFunc
+	Column string
+	Alias  string // name of the result column, defaults to e.g. "sum(price)"
+}
+
+func Count(column string) Aggregate { return Aggregate{Func: AggCount, Column: column} }
+func Sum(column string) Aggregate   { return Aggregate{Func: AggSum, Column: column} }
+func Min(column string) Aggregate   { return Aggregate{Func: AggMin, Column: column} }
+func Max(column string) Aggregate   { return Aggregate{Func: AggMax, Column: column} }
+func Avg(column string) Aggregate   { return Aggregate{Func: AggAvg, Column: column} }
+
+// As names the result column of the aggregate.
+func (a Aggregate) As(alias string) Aggregate {
+	a.Alias = alias
+	return a
+}
+
+// Name is the result column the aggregate is returned in.
+func (a Aggregate) Name() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	return strings.ToLower(string(a.Func)) + "(" + a.Column + ")"
+}
+
+func isNumeric(colType ColumnType) bool {
+	return colType == TypeInt || colType == TypeFloat
+}
+
+// checkAggregates validates the GROUP BY columns and aggregates of opts and returns
+// the columns of the grouped rows.
+func checkAggregates(tableColumns []*Column, opts QueryOptions) ([]string, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, name := range opts.GroupBy {
+		if findColumn(tableColumns, name) == nil {
+			return nil, unknownColumn(name)
+		}
+		if !seen[name] {
+			columns = append(columns, name)
+			seen[name] = true
+		}
+	}
+	for _, agg := range opts.Aggregates {
+		switch agg.Func {
+		case AggCount, AggSum, AggMin, AggMax, AggAvg:
+		default:
+			return nil, fmt.Errorf("unknown aggregate %s", agg.Func)
+		}
+		if agg.Column != "*" || agg.Func != AggCount {
+			col := findColumn(tableColumns, agg.Column)
+			if col == nil {
+				return nil, unknownColumn(agg.Column)
+			}
+			if (agg.Func == AggSum || agg.Func == AggAvg) && !isNumeric(col.Type) {
+				return nil, fmt.Errorf("%s needs a numeric column, %s is %s", agg.Func, col.Name, col.Type)
+			}
+		}
+		if seen[agg.Name()] {
+			return nil, fmt.Errorf("duplicate result column %s", agg.Name())
+		}
+		columns = append(columns, agg.Name())
+		seen[agg.Name()] = true
+	}
+	for _, colName := range predicateColumns(opts.Having) {
+		if !seen[colName] {
+			return nil, fmt.Errorf("HAVING can only use grouped columns and aggregates, not %s", colName)
+		}
+	}
+	return columns, nil
+}
+
+// aggregate groups refs by opts.GroupBy and returns one row per group that passes
+// opts.Having. Groups keep the order in which they were first seen; with no GROUP BY
+// there is exactly one group, even over no rows. It fails if a SUM of ints overflows.
+func aggregate(columns []*Column, refs []rowRef, opts QueryOptions) ([]rowRef, error) {
+	type group struct {
+		key    map[string]any
+		states []*aggState
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	newGroup := func(key map[string]any) *group {
+		g := &group{key: key}
+		for _, agg := range opts.Aggregates {
+			g.states = append(g.states, &aggState{agg: agg, col: findColumn(columns, agg.Column)})
+		}
+		groups = append(groups, g)
+		return g
+	}
+	if len(opts.GroupBy) == 0 {
+		newGroup(nil)
+	}
+
+	for _, ref := range refs {
+		var g *group
+		if len(opts.GroupBy) == 0 {
+			g = groups[0]
+		} else {
+			parts := make([]any, len(opts.GroupBy))
+			for i, name := range opts.GroupBy {
+				parts[i] = normalizeKey(ref.data[name])
+			}
+			encoded, _ := json.Marshal(parts)
+			if g = byKey[string(encoded)]; g == nil {
+				key := make(map[string]any, len(opts.GroupBy))
+				for _, name := range opts.GroupBy {
+					if val := ref.data[name]; val != nil {
+						key[name] = val
+					}
+				}
+				g = newGroup(key)
+				byKey[string(encoded)] = g
+			}
+		}
+		for _, state := range g.states {
+			state.add(ref.data)
+		}
+	}
+
+	var out []rowRef
+	for i, g := range groups {
+		row := make(map[string]any, len(g.key)+len(g.states))
+		for name, val := range g.key {
+			row[name] = val
+		}
+		for _, state := range g.states {
+			val, err := state.result()
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				row[state.agg.Name()] = val
+			}
+		}
+		if rowMatches(row, opts.Having) {
+			out = append(out, rowRef{id: int64(i), data: row})
+		}
+	}
+	return out, nil
+}
+
+// aggState accumulates one aggregate over one group.
+type aggState struct {
+	agg      Aggregate
+	col      *Column // nil for COUNT(*)
+	count    int64
+	isum     int64
+	overflow bool // isum no longer fits an int64
+	fsum     float64
+	best     any // MIN or MAX so far
+}
+
+func (s *aggState) add(row map[string]any) {
+	if s.col == nil {
+		s.count++
+		return
+	}
+	val := row[s.col.Name]
+	if val == nil {
+		return
+	}
+	s.count++
+	switch s.agg.Func {
+	case AggSum, AggAvg:
+		if n, err := convertToInt(val); err == nil {
+			sum := s.isum + n
+			if (n > 0 && sum < s.isum) || (n < 0 && sum > s.isum) {
+				s.overflow = true
+			}
+			s.isum = sum
+		}
+		f, _ := convertToFloat(val)
+		s.fsum += f
+	case AggMin:
+		if c, ok := compareValues(val, s.best); s.best == nil || ok && c < 0 {
+			s.best = val
+		}
+	case AggMax:
+		if c, ok := compareValues(val, s.best); s.best == nil || ok && c > 0 {
+			s.best = val
+		}
+	}
+}
+
+func (s *aggState) result() (any, error) {
+	switch s.agg.Func {
+	case AggCount:
+		return s.count, nil
+	case AggMin, AggMax:
+		return s.best, nil
+	}
+	if s.count == 0 {
+		return nil, nil
+	}
+	switch {
+	case s.agg.Func == AggAvg:
+		return s.fsum / float64(s.count), nil
+	case s.col.Type == TypeInt:
+		if s.overflow {
+			return nil, fmt.Errorf("%s is out of range for int", s.agg.Name())
+		}
+		return s.isum, nil
+	}
+	return s.fsum, nil
+}
+
+// aggregateNode describes the aggregation of input rows into groups for Explain.
+func aggregateNode(child *PlanNode, e estimator, opts QueryOptions, input, groups int) *PlanNode {
+	estimated := 1.0
+	for _, name := range opts.GroupBy {
+		estimated *= float64(max(e[name].distinct, 1))
+	}
+	estimated = math.Min(estimated, math.Max(child.Estimated, 1))
+	if opts.Having != nil {
+		estimated *= rangeSelectivity
+	}
+	var details []string
+	if len(opts.GroupBy) > 0 {
+		details = append(details, "by "+strings.Join(opts.GroupBy, ", "))
+	}
+	if opts.Having != nil {
+		details = append(details, fmt.Sprintf("having %v", opts.Having))
+	}
+	return &PlanNode{Op: "Aggregate", Detail: strings.Join(details, " "), Cost: child.Cost + float64(input)*hashRowCost,
+		Estimated: estimated, Actual: groups, Children: []*PlanNode{child}}
+}
@@ -0,0 +1,87 @@
+package sqldb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateGroupBy(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	rs, err := db.Select("players", QueryOptions{
+		GroupBy:    []string{"team"},
+		Aggregates: []Aggregate{Count("*"), Count("score"), Sum("score"), Min("score"), Max("name"), Avg("score").As("mean")},
+		OrderBy:    []OrderBy{Asc("team")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"team": "blue", "count(*)": int64(2), "count(score)": int64(1), "sum(score)": int64(5), "min(score)": int64(5), "max(name)": "dee", "mean": 5.0},
+		{"team": "red", "count(*)": int64(3), "count(score)": int64(3), "sum(score)": int64(9), "min(score)": int64(1), "max(name)": "eve", "mean": 3.0},
+	}
+	if !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("got %v, want %v", rs.Rows, want)
+	}
+	wantColumns := []string{"team", "count(*)", "count(score)", "sum(score)", "min(score)", "max(name)", "mean"}
+	if !reflect.DeepEqual(rs.Columns, wantColumns) {
+		t.Fatalf("columns = %v", rs.Columns)
+	}
+}
+
+func TestAggregateWhereAndHaving(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	rs, err := db.Select("players", QueryOptions{
+		Where:      Ge("score", 3),
+		GroupBy:    []string{"team"},
+		Aggregates: []Aggregate{Count("*").As("n")},
+		Having:     Gt("n", 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[string]any{{"team": "red", "n": int64(2)}}; !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("got %v, want %v", rs.Rows, want)
+	}
+
+	// without GROUP BY there is one group, even over no rows
+	rs, err = db.Select("players", QueryOptions{Where: Eq("team", "green"), Aggregates: []Aggregate{Count("*"), Sum("score")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[string]any{{"count(*)": int64(0)}}; !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("aggregate over no rows = %v, want %v", rs.Rows, want)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	for name, opts := range map[string]QueryOptions{
+		"sum of a string":  {Aggregates: []Aggregate{Sum("name")}},
+		"unknown column":   {Aggregates: []Aggregate{Max("nope")}},
+		"unknown group":    {GroupBy: []string{"nope"}, Aggregates: []Aggregate{Count("*")}},
+		"unknown function": {Aggregates: []Aggregate{{Func: "MEDIAN", Column: "score"}}},
+		"duplicate column": {Aggregates: []Aggregate{Count("*"), Count("*")}},
+		"ungrouped HAVING": {GroupBy: []string{"team"}, Aggregates: []Aggregate{Count("*")}, Having: Eq("name", "ada")},
+	} {
+		if _, err := db.Select("players", opts); err == nil {
+			t.Errorf("%s: succeeded", name)
+		}
+	}
+}
+
+func TestSumOverflow(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "big", []*Column{NewColumn("n", TypeInt)},
+		map[string]any{"n": int64(math.MaxInt64)}, map[string]any{"n": 1}, map[string]any{"n": -2})
+	if _, err := db.Select("big", QueryOptions{Aggregates: []Aggregate{Sum("n")}}); err == nil {
+		t.Fatal("SUM past MaxInt64 succeeded")
+	}
+	rs, err := db.Select("big", QueryOptions{Where: Lt("n", 2), Aggregates: []Aggregate{Sum("n")}})
+	if err != nil || rs.Rows[0]["sum(n)"] != int64(-1) {
+		t.Fatalf("SUM = %v, %v, want -1", rs, err)
+	}
+}
@@ -0,0 +1,308 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Alteration is one schema change applied by AlterTable.
+type Alteration func(*alterPlan) error
+
+// alterPlan is the schema a table is being altered to, plus how to fill each of its
+// columns from the current rows.
+type alterPlan struct {
+	columns  []*Column
+	checks   []TableCheck
+	source   map[string]string // column -> column of the current rows it is read from
+	defaults map[string]any    // value of added columns in existing rows
+}
+
+func (plan *alterPlan) position(name string) int {
+	for i, col := range plan.columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddColumn adds col to the table, setting it to defaultValue in every existing row.
+// A nil defaultValue falls back to the column's own Default, or one value of its
+// DefaultFunc.
+func AddColumn(col *Column, defaultValue any) Alteration {
+	return func(plan *alterPlan) error {
+		if plan.position(col.Name) >= 0 {
+			return fmt.Errorf("column %s already exists", col.Name)
+		}
+		copied := *col
+		plan.columns = append(plan.columns, &copied)
+		if defaultValue == nil {
+			defaultValue = col.Constraints.Default
+		}
+		if defaultValue == nil && col.Constraints.DefaultFunc != nil {
+			defaultValue = col.Constraints.DefaultFunc()
+		}
+		if defaultValue != nil {
+			plan.defaults[col.Name] = defaultValue
+		}
+		return nil
+	}
+}
+
+func DropColumn(name string) Alteration {
+	return func(plan *alterPlan) error {
+		i := plan.position(name)
+		if i < 0 {
+			return unknownColumn(name)
+		}
+		plan.columns = append(plan.columns[:i:i], plan.columns[i+1:]...)
+		delete(plan.source, name)
+		delete(plan.defaults, name)
+		return nil
+	}
+}
+
+func RenameColumn(name, newName string) Alteration {
+	return func(plan *alterPlan) error {
+		i := plan.position(name)
+		if i < 0 {
+			return unknownColumn(name)
+		}
+		if plan.position(newName) >= 0 {
+			return fmt.Errorf("column %s already exists", newName)
+		}
+		renamed := *plan.columns[i]
+		renamed.Name = newName
+		plan.columns[i] = &renamed
+		if src, ok := plan.source[name]; ok {
+			plan.source[newName] = src
+			delete(plan.source, name)
+		}
+		if val, ok := plan.defaults[name]; ok {
+			plan.defaults[newName] = val
+			delete(plan.defaults, name)
+		}
+		return nil
+	}
+}
+
+// ModifyColumn replaces the type and constraints of the column named col.Name with
+// those of col, which tightens or loosens the constraints. Existing values are
+// converted to the new type where the conversion is unambiguous.
+func ModifyColumn(col *Column) Alteration {
+	return func(plan *alterPlan) error {
+		i := plan.position(col.Name)
+		if i < 0 {
+			return unknownColumn(col.Name)
+		}
+		copied := *col
+		plan.columns[i] = &copied
+		return nil
+	}
+}
+
+// AddCheck adds a table-level check, which every existing row must pass.
+func AddCheck(check TableCheck) Alteration {
+	return func(plan *alterPlan) error {
+		plan.checks = append(plan.checks, check)
+		return nil
+	}
+}
+
+func DropCheck(name string) Alteration {
+	return func(plan *alterPlan) error {
+		for i, check := range plan.checks {
+			if check.Name == name {
+				plan.checks = append(plan.checks[:i:i], plan.checks[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown check %s", name)
+	}
+}
+
+// AlterTable applies the alterations in order and checks every row against the
+// resulting schema. If a row violates it, or any alteration fails, the table is left
+// as it was.
+//
+// Transactions that wrote to the table before the change fail to commit, and *Table
+// values obtained before it keep the old schema and are no longer part of the database.
+func (db *Database) AlterTable(name string, alterations ...Alteration) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, ok := db.tables[name]
+	if !ok {
+		return tableNotFound(name)
+	}
+	plan := &alterPlan{checks: table.Checks, source: make(map[string]string), defaults: make(map[string]any)}
+	for _, col := range table.Columns {
+		plan.columns = append(plan.columns, col)
+		plan.source[col.Name] = col.Name
+	}
+	for _, alteration := range alterations {
+		if err := alteration(plan); err != nil {
+			return err
+		}
+	}
+	if err := validateSchema(plan.columns, plan.checks); err != nil {
+		return err
+	}
+	if err := db.tm.checkLoggable(plan.columns, plan.checks); err != nil {
+		return err
+	}
+	if err := db.checkForeignKeys(name, plan.columns); err != nil {
+		return err
+	}
+	if err := db.checkReferencedKey(table, plan); err != nil {
+		return err
+	}
+
+	rec, err := alterRecord(name, plan)
+	if err != nil {
+		return err
+	}
+	return db.alterTable(table, plan, func() error {
+		return db.tm.logRecord(rec)
+	})
+}
+
+// alterTable builds the altered table and, if every row fits the new schema, calls
+// commit and then replaces table with it in the catalog. The caller must hold db.mu.
+func (db *Database) alterTable(table *Table, plan *alterPlan, commit func() error) error {
+	// no commit can touch the table while its rows are copied
+	table.mu.Lock()
+	defer table.mu.Unlock()
+
+	altered := NewTable(table.Name, plan.columns, plan.checks...)
+	altered.db = db
+	altered.tm = db.tm
+	for _, idx := range table.Indexes() {
+		for _, col := range plan.columns {
+			if plan.source[col.Name] == idx.Column && altered.indexes[col.Name] == nil {
+				altered.indexes[col.Name] = newIndex(col, idx.Kind)
+			}
+		}
+	}
+	// every version is carried over, so snapshots taken before the change still see
+	// the rows they saw; only current versions have to satisfy the new schema
+	old := *table.versions.Load()
+	versions := make([]*rowVersion, 0, len(old))
+	current := make(map[int64]map[string]any)
+	for _, v := range old {
+		xmax := v.xmax.Load()
+		data := make(map[string]any, len(plan.columns))
+		for _, col := range plan.columns {
+			val := plan.defaults[col.Name]
+			if src, ok := plan.source[col.Name]; ok {
+				val = v.data[src]
+			}
+			if xmax == 0 && col.Constraints.Computed != nil {
+				// filled in below, from the rest of the row
+				continue
+			}
+			if xmax == 0 {
+				coerced, err := col.Coerce(val)
+				if err != nil {
+					return fmt.Errorf("row %d: %w", v.id, err)
+				}
+				val = coerced
+			} else if converted, err := col.convert(val); err == nil {
+				val = converted
+			}
+			if val != nil {
+				data[col.Name] = val
+			}
+		}
+		if xmax == 0 {
+			if err := altered.recompute(data); err != nil {
+				return fmt.Errorf("row %d: %w", v.id, err)
+			}
+			if violations := altered.checkRow(data); len(violations) > 0 {
+				return fmt.Errorf("row %d: %w", v.id, errors.Join(violations...))
+			}
+		}
+		nv := &rowVersion{id: v.id, data: data, xmin: v.xmin}
+		nv.xmax.Store(xmax)
+		versions = append(versions, nv)
+		if xmax == 0 {
+			current[v.id] = data
+		}
+	}
+	if err := newWriteSet(altered).checkUnique(0, current); err != nil {
+		return err
+	}
+	// altered is not in the catalog yet, so it can be filled before the checks
+	for _, idx := range altered.indexes {
+		for _, v := range versions {
+			idx.insert(v)
+		}
+	}
+	altered.versions.Store(&versions)
+	altered.nextID.Store(table.nextID.Load())
+	altered.autoInc.Store(table.autoInc.Load())
+	altered.triggers.Store(table.triggers.Load())
+	for _, data := range current {
+		altered.observeAutoIncrement(data)
+	}
+	altered.dead = table.dead
+	if err := db.checkReferencedRows(altered); err != nil {
+		return err
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+	db.tables[table.Name] = altered
+	return nil
+}
+
+// checkReferencedKey makes sure the primary key other tables reference survives an
+// alteration with its values and type.
+func (db *Database) checkReferencedKey(table *Table, plan *alterPlan) error {
+	for _, ref := range db.referencing(table.Name) {
+		if ref.table == table {
+			// self references are checked against the altered rows
+			continue
+		}
+		old, pk := table.PrimaryKey(), primaryKey(plan.columns)
+		if pk == nil || plan.source[pk.Name] != old.Name || pk.Type != old.Type {
+			return fmt.Errorf("primary key %s.%s is referenced from %s.%s", table.Name, old.Name, ref.table.Name, ref.column.Name)
+		}
+	}
+	return nil
+}
+
+func alterRecord(name string, plan *alterPlan) (*walRecord, error) {
+	defs, err := encodeColumns(plan.columns)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := encodeRow(plan.defaults)
+	if err != nil {
+		return nil, err
+	}
+	return &walRecord{Op: walAlterTable, Table: name, Columns: defs, Sources: plan.source, Defaults: defaults}, nil
+}
+
+// replayAlter rebuilds the plan of a logged AlterTable and applies it.
+func (db *Database) replayAlter(rec *walRecord) error {
+	table, ok := db.tables[rec.Table]
+	if !ok {
+		return tableNotFound(rec.Table)
+	}
+	columns, err := decodeColumns(rec.Columns)
+	if err != nil {
+		return err
+	}
+	plan := &alterPlan{columns: columns, source: rec.Sources, defaults: make(map[string]any)}
+	if plan.source == nil {
+		plan.source = make(map[string]string)
+	}
+	if rec.Defaults != nil {
+		if plan.defaults, err = decodeRow(columns, rec.Defaults); err != nil {
+			return err
+		}
+	}
+	return db.alterTable(table, plan, func() error { return nil })
+}
@@ -0,0 +1,116 @@
+package sqldb
+
+import (
+	"slices"
+	"testing"
+)
+
+func columnNames(t *testing.T, db *Database, table string) []string {
+	t.Helper()
+	tbl, err := db.GetTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, col := range tbl.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+func TestAlterTableColumns(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns(), map[string]any{"id": 1, "name": "ada"}, map[string]any{"id": 2, "name": "linus"})
+	if err := db.CreateIndex("users", "name", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	err := db.AlterTable("users",
+		AddColumn(NewColumn("active", TypeBool, Required()), true),
+		RenameColumn("name", "login"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := columnNames(t, db, "users"); !slices.Equal(names, []string{"id", "login", "active"}) {
+		t.Fatalf("columns = %v", names)
+	}
+	row, _ := db.GetByPrimaryKey("users", 2)
+	if row["login"] != "linus" || row["active"] != true || row["name"] != nil {
+		t.Fatalf("altered row = %v", row)
+	}
+	table, _ := db.GetTable("users")
+	if table.index("login") == nil {
+		t.Fatal("index did not follow the renamed column")
+	}
+
+	if err := db.AlterTable("users", DropColumn("active")); err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.GetByPrimaryKey("users", 1); len(row) != 2 {
+		t.Fatalf("row after dropping a column = %v", row)
+	}
+	if err := db.InsertRecord("users", map[string]any{"id": 3, "login": "x", "active": false}); err == nil {
+		t.Fatal("insert into a dropped column succeeded")
+	}
+}
+
+func TestAlterTableConstraints(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns(), map[string]any{"id": 1, "name": "ada"}, map[string]any{"id": 2, "name": "linus"})
+	// "linus" is too long for the tightened column, so nothing changes
+	err := db.AlterTable("users",
+		AddColumn(NewColumn("age", TypeInt), 30),
+		ModifyColumn(NewColumn("name", TypeString, Required(), MaxLength(3))),
+	)
+	if err == nil {
+		t.Fatal("tightening past an existing row succeeded")
+	}
+	if names := columnNames(t, db, "users"); !slices.Equal(names, []string{"id", "name"}) {
+		t.Fatalf("failed alteration left columns %v", names)
+	}
+
+	if err := db.AlterTable("users", ModifyColumn(NewColumn("name", TypeString, MaxLength(10)))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRecord("users", map[string]any{"id": 3, "name": "margaret"}); err != nil {
+		t.Fatalf("loosened column rejects a longer name: %v", err)
+	}
+	if err := db.InsertRecord("users", map[string]any{"id": 4}); err != nil {
+		t.Fatalf("column no longer required rejects a missing name: %v", err)
+	}
+
+	for name, alteration := range map[string]Alteration{
+		"add":    AddColumn(NewColumn("id", TypeInt), nil),
+		"drop":   DropColumn("nope"),
+		"rename": RenameColumn("name", "id"),
+		"modify": ModifyColumn(NewColumn("nope", TypeInt)),
+	} {
+		if err := db.AlterTable("users", alteration); err == nil {
+			t.Errorf("bad %s alteration succeeded", name)
+		}
+	}
+	if err := db.AlterTable("nope", DropColumn("id")); err == nil {
+		t.Fatal("altering a missing table succeeded")
+	}
+}
+
+func TestAlterTableIsLogged(t *testing.T) {
+	dir := openWithRows(t, 2)
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AlterTable("users", AddColumn(NewColumn("name", TypeString), "anon")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if row, _ := db.GetByPrimaryKey("users", 2); row["name"] != "anon" {
+		t.Fatalf("reopened row = %v, want the added column", row)
+	}
+}
@@ -0,0 +1,284 @@
+package sqldb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	maxImportErrors = 20       // line errors an import reports before it gives up
+	maxJSONLLine    = 64 << 20 // longest line ImportJSONL reads
+)
+
+// InsertMany validates every record, including uniqueness across the batch, and adds
+// them all in one commit, or none of them.
+func (db *Database) InsertMany(tableName string, records []map[string]any) error {
+	_, err := db.insertRows(tableName, records)
+	return err
+}
+
+// InsertMany is Database.InsertMany inside the transaction.
+func (tx *Tx) InsertMany(tableName string, records []map[string]any) error {
+	_, err := tx.insertRows(tableName, records)
+	return err
+}
+
+// ImportCSV inserts the records of a CSV file whose first line names the columns. An
+// empty field leaves its column out, making it NULL unless the column generates a value
+// as on insert; computed columns are ignored. Bytes are base64 and timestamps are in one
+// of the formats a timestamp column accepts. Every line is checked before any record is
+// inserted, and then all of them are inserted in one commit; the errors of up to 20
+// lines are reported with their line numbers. It returns the number of records inserted.
+func (db *Database) ImportCSV(tableName string, r io.Reader) (int, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	columns := make([]*Column, len(header))
+	for i, name := range header {
+		if columns[i] = table.GetColumn(name); columns[i] == nil {
+			return 0, fmt.Errorf("line 1: %w", unknownColumn(name))
+		}
+		for _, prev := range header[:i] {
+			if prev == name {
+				return 0, fmt.Errorf("line 1: duplicate column %s", name)
+			}
+		}
+	}
+
+	imp := &importer{table: table}
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// csv errors carry their line already
+			return 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		record := make(map[string]any, len(fields))
+		var errs []error
+		for i, text := range fields {
+			val, err := parseCSVValue(table.Name, columns[i], text)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if val != nil {
+				record[columns[i].Name] = val
+			}
+		}
+		if !imp.add(line, record, errs) {
+			break
+		}
+	}
+	return imp.insert()
+}
+
+// ImportJSONL inserts the records of a file holding one JSON object per line, in the
+// format ExportJSONL writes. Blank lines are skipped; otherwise it works like ImportCSV.
+func (db *Database) ImportJSONL(tableName string, r io.Reader) (int, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxJSONLLine)
+	imp := &importer{table: table}
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var encoded map[string]json.RawMessage
+		if err := json.Unmarshal(text, &encoded); err != nil {
+			if !imp.add(line, nil, []error{err}) {
+				break
+			}
+			continue
+		}
+		record, err := decodeRow(table.Columns, encoded)
+		var errs []error
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if !imp.add(line, record, errs) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return imp.insert()
+}
+
+// importer collects the records of an import and the errors of its lines.
+type importer struct {
+	table   *Table
+	records []map[string]any
+	errs    []error
+}
+
+// add checks the record read from line, unless reading it already failed with errs.
+// Values of computed columns are dropped, as the insert computes them again, and the
+// record is checked with its generated values filled in. It returns false once too
+// many lines failed.
+func (imp *importer) add(line int, record map[string]any, errs []error) bool {
+	for _, col := range imp.table.Columns {
+		if col.Constraints.Computed != nil {
+			delete(record, col.Name)
+		}
+	}
+	if len(errs) == 0 {
+		next := func() int64 { return imp.table.autoInc.Load() + 1 }
+		row, err := imp.table.generateWith(record, next)
+		if err == nil {
+			_, err = imp.table.prepareRow(row)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		imp.errs = append(imp.errs, fmt.Errorf("line %d: %w", line, errors.Join(errs...)))
+		if len(imp.errs) == maxImportErrors {
+			imp.errs = append(imp.errs, fmt.Errorf("too many errors, stopped at line %d", line))
+			return false
+		}
+		return true
+	}
+	imp.records = append(imp.records, record)
+	return true
+}
+
+func (imp *importer) insert() (int, error) {
+	if len(imp.errs) > 0 {
+		return 0, errors.Join(imp.errs...)
+	}
+	if len(imp.records) == 0 {
+		return 0, nil
+	}
+	if err := imp.table.AddRows(imp.records); err != nil {
+		return 0, err
+	}
+	return len(imp.records), nil
+}
+
+func parseCSVValue(table string, col *Column, text string) (any, error) {
+	if text == "" {
+		return nil, nil
+	}
+	var val any
+	var err error
+	switch col.Type {
+	case TypeInt:
+		val, err = strconv.ParseInt(text, 10, 64)
+	case TypeFloat:
+		val, err = strconv.ParseFloat(text, 64)
+	case TypeBool:
+		val, err = strconv.ParseBool(text)
+	case TypeBytes:
+		val, err = base64.StdEncoding.DecodeString(text)
+	default:
+		// strings as they are, timestamps and JSON are parsed by the column
+		val = text
+	}
+	if err != nil {
+		return nil, col.violation(table, ConstraintType, text, "expected %s but got %q", col.Type, text)
+	}
+	return val, nil
+}
+
+// ExportCSV writes the records of a table as CSV in the format ImportCSV reads, with a
+// header line naming the columns. Records are written in insertion order, as seen by a
+// snapshot of the latest commit.
+func (db *Database) ExportCSV(tableName string, w io.Writer) error {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	header := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		header[i] = col.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range table.GetRowsWhere(nil) {
+		fields := make([]string, len(table.Columns))
+		for i, col := range table.Columns {
+			fields[i] = formatCSVValue(row[col.Name])
+		}
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case json.RawMessage:
+		return string(v)
+	}
+	return fmt.Sprint(val)
+}
+
+// ExportJSONL writes the records of a table as one JSON object per line, with a key for
+// every column in table order. It reads like ExportCSV.
+func (db *Database) ExportJSONL(tableName string, w io.Writer) error {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, row := range table.GetRowsWhere(nil) {
+		encoded, err := encodeRow(row)
+		if err != nil {
+			return err
+		}
+		bw.WriteByte('{')
+		for i, col := range table.Columns {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			name, _ := json.Marshal(col.Name)
+			bw.Write(name)
+			bw.WriteByte(':')
+			if raw, ok := encoded[col.Name]; ok {
+				bw.Write(raw)
+			} else {
+				bw.WriteString("null")
+			}
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
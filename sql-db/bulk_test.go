@@ -0,0 +1,160 @@
+package sqldb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInsertManyIsAtomic(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	err := db.InsertMany("users", []map[string]any{
+		{"id": 1, "name": "ada"},
+		{"id": 2, "name": "linus"},
+		{"id": 1, "name": "grace"}, // repeats the key of the first record
+	})
+	if err == nil {
+		t.Fatal("InsertMany with a duplicate key succeeded")
+	}
+	if n := rowCount(t, db, "users"); n != 0 {
+		t.Fatalf("got %d rows after a failed InsertMany, want none", n)
+	}
+
+	if err := db.InsertMany("users", []map[string]any{{"id": 1, "name": "ada"}, {"id": 2, "name": "linus"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := rowCount(t, db, "users"); n != 2 {
+		t.Fatalf("got %d rows, want 2", n)
+	}
+}
+
+func TestTxInsertManyRollsBack(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	tx := db.Begin()
+	if err := tx.InsertMany("users", []map[string]any{{"id": 1, "name": "ada"}, {"id": 2, "name": "toolong"}}); err == nil {
+		t.Fatal("InsertMany with an invalid record succeeded")
+	}
+	// the failed statement is undone on its own, the transaction carries on
+	if err := tx.InsertMany("users", []map[string]any{{"id": 3, "name": "grace"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := db.GetRecords("users", nil)
+	if len(rows) != 1 || rows[0]["id"] != int64(3) {
+		t.Fatalf("got %v, want only user 3", rows)
+	}
+}
+
+func TestImportCSVReportsEveryBadLine(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	csv := "id,name\n1,ada\nx,linus\n3,toolong\n4,grace\n"
+	_, err := db.ImportCSV("users", strings.NewReader(csv))
+	if err == nil {
+		t.Fatal("ImportCSV with bad lines succeeded")
+	}
+	for _, line := range []string{"line 3", "line 4"} {
+		if !strings.Contains(err.Error(), line) {
+			t.Errorf("error %q doesn't report %s", err, line)
+		}
+	}
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) {
+		t.Errorf("error %q has no ConstraintViolation", err)
+	}
+	if n := rowCount(t, db, "users"); n != 0 {
+		t.Fatalf("got %d rows after a failed import, want none", n)
+	}
+}
+
+func TestCSVAndJSONLRoundTrip(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	db.InsertMany("users", []map[string]any{{"id": 1, "name": "ada"}, {"id": 2, "name": "a,\"b"}})
+
+	for _, format := range []struct {
+		name   string
+		export func(*Database, string, *bytes.Buffer) error
+		load   func(*Database, string, *bytes.Buffer) (int, error)
+	}{
+		{"csv",
+			func(db *Database, table string, buf *bytes.Buffer) error { return db.ExportCSV(table, buf) },
+			func(db *Database, table string, buf *bytes.Buffer) (int, error) { return db.ImportCSV(table, buf) }},
+		{"jsonl",
+			func(db *Database, table string, buf *bytes.Buffer) error { return db.ExportJSONL(table, buf) },
+			func(db *Database, table string, buf *bytes.Buffer) (int, error) { return db.ImportJSONL(table, buf) }},
+	} {
+		var buf bytes.Buffer
+		if err := format.export(db, "users", &buf); err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		copied := NewDatabase()
+		createTable(t, copied, "users", usersColumns())
+		if n, err := format.load(copied, "users", &buf); err != nil || n != 2 {
+			t.Fatalf("%s: imported %d rows, %v", format.name, n, err)
+		}
+		row, err := copied.GetByPrimaryKey("users", 2)
+		if err != nil || row["name"] != "a,\"b" || row["id"] != int64(2) {
+			t.Fatalf("%s: got %v, %v", format.name, row, err)
+		}
+	}
+}
+
+func TestImportGeneratedColumns(t *testing.T) {
+	orderColumns := func() []*Column {
+		return []*Column{
+			NewColumn("id", TypeInt, PrimaryKey(), AutoIncrement()),
+			NewColumn("status", TypeString, Required(), Default("new")),
+			NewColumn("qty", TypeInt, Required()),
+			NewColumn("double", TypeInt, Computed(func(row map[string]any) any {
+				n, _ := convertToInt(row["qty"])
+				return 2 * n
+			})),
+		}
+	}
+	db := NewDatabase()
+	createTable(t, db, "orders", orderColumns())
+	// the records leave out the required auto-increment and default columns
+	if n, err := db.ImportCSV("orders", strings.NewReader("qty\n1\n2\n")); err != nil || n != 2 {
+		t.Fatalf("imported %d rows, %v", n, err)
+	}
+	if n, err := db.ImportJSONL("orders", strings.NewReader(`{"qty": 3}`)); err != nil || n != 1 {
+		t.Fatalf("imported %d rows, %v", n, err)
+	}
+	// checking the records didn't use up ids
+	if id, err := db.InsertReturningID("orders", map[string]any{"qty": 4}); err != nil || id != 4 {
+		t.Fatalf("InsertReturningID = %d, %v, want 4", id, err)
+	}
+	want, _ := db.GetRecords("orders", nil)
+	if row := want[0]; row["id"] != int64(1) || row["status"] != "new" || row["double"] != int64(2) {
+		t.Fatalf("first imported row = %v", row)
+	}
+
+	// exports hold the computed column, which imports recompute
+	var csvBuf, jsonlBuf bytes.Buffer
+	if err := db.ExportCSV("orders", &csvBuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ExportJSONL("orders", &jsonlBuf); err != nil {
+		t.Fatal(err)
+	}
+	for name, load := range map[string]func(*Database) (int, error){
+		"csv":   func(db *Database) (int, error) { return db.ImportCSV("orders", &csvBuf) },
+		"jsonl": func(db *Database) (int, error) { return db.ImportJSONL("orders", &jsonlBuf) },
+	} {
+		copied := NewDatabase()
+		createTable(t, copied, "orders", orderColumns())
+		if n, err := load(copied); err != nil || n != len(want) {
+			t.Fatalf("%s: imported %d rows, %v", name, n, err)
+		}
+		if got, _ := copied.GetRecords("orders", nil); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: round trip gave %v, want %v", name, got, want)
+		}
+	}
+}
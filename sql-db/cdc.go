@@ -0,0 +1,183 @@
+package sqldb
+
+import "sync"
+
+// ChangeOp is the kind of row change a ChangeEvent reports.
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "insert"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete"
+)
+
+// defaultChangeBuffer is the number of events a subscription buffers unless
+// SubscribeOptions says otherwise.
+const defaultChangeBuffer = 1024
+
+// ChangeEvent is one committed row change. Before is nil for an insert and After for
+// a delete; both are copies the receiver may modify.
+type ChangeEvent struct {
+	Seq    uint64 // orders the events of every table of the database, increasing
+	Commit uint64 // commit the change was part of, shared by the events of a transaction
+	Table  string
+	Op     ChangeOp
+	Before map[string]any
+	After  map[string]any
+}
+
+type SubscribeOptions struct {
+	Buffer int // events held for a slow receiver, 1024 if zero
+}
+
+// subscription is the sending end of a Subscribe channel.
+type subscription struct {
+	mu     sync.Mutex // guards ch against being closed during a send
+	ch     chan ChangeEvent
+	closed bool
+}
+
+func (s *subscription) send(ev ChangeEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.ch <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// changeFeed holds the subscriptions of a database, by table name.
+type changeFeed struct {
+	mu   sync.Mutex
+	subs map[string][]*subscription
+	seq  uint64 // last event sequence number, guarded by txManager.commitMu
+}
+
+// Subscribe streams the changes committed to a table from now on, in commit order.
+// The changes of one transaction arrive together, ordered by table and row.
+//
+// Writers never wait for receivers. A subscription buffers opts.Buffer events; a
+// receiver that falls further behind has its channel closed, after the events
+// already buffered, rather than silently missing some. A channel that closes without
+// cancel being called therefore means the receiver has to resubscribe and read the
+// table again. cancel ends the subscription and closes the channel; it may be called
+// more than once. Subscriptions outlive AlterTable and DeleteTable, following the
+// table by name.
+func (db *Database) Subscribe(tableName string, opts SubscribeOptions) (<-chan ChangeEvent, func()) {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = defaultChangeBuffer
+	}
+	sub := &subscription{ch: make(chan ChangeEvent, buffer)}
+	feed := &db.tm.feed
+	feed.mu.Lock()
+	if feed.subs == nil {
+		feed.subs = make(map[string][]*subscription)
+	}
+	feed.subs[tableName] = append(feed.subs[tableName], sub)
+	feed.mu.Unlock()
+	return sub.ch, func() {
+		feed.unsubscribe(tableName, sub)
+		sub.close()
+	}
+}
+
+func (feed *changeFeed) unsubscribe(tableName string, sub *subscription) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	feed.remove(tableName, sub)
+}
+
+// remove drops sub from the subscribers of a table. The caller holds feed.mu.
+func (feed *changeFeed) remove(tableName string, sub *subscription) {
+	subs := feed.subs[tableName]
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(feed.subs, tableName)
+	} else {
+		feed.subs[tableName] = subs
+	}
+}
+
+// publish sends the changes of a commit to the subscribers of their tables. The
+// caller holds tm.commitMu, so events are numbered and sent in commit order.
+func (feed *changeFeed) publish(commit uint64, sets []*writeSet) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if len(feed.subs) == 0 {
+		return
+	}
+	for _, ws := range sets {
+		subs := feed.subs[ws.table.Name]
+		if len(subs) == 0 {
+			continue
+		}
+		for _, id := range sortedIDs(ws.rows) {
+			ev, ok := changeEvent(ws.rows[id])
+			if !ok {
+				continue
+			}
+			feed.seq++
+			ev.Seq, ev.Commit, ev.Table = feed.seq, commit, ws.table.Name
+			for _, sub := range subs {
+				// every receiver gets rows of its own, copied from ev, which none of
+				// them can reach
+				received := ev
+				received.Before, received.After = cloneRow(ev.Before), cloneRow(ev.After)
+				if !sub.send(received) {
+					// fell behind: end the subscription instead of blocking the writer
+					feed.remove(ws.table.Name, sub)
+					sub.close()
+				}
+			}
+		}
+	}
+}
+
+func cloneRow(row map[string]any) map[string]any {
+	if row == nil {
+		return nil
+	}
+	return copyRow(row)
+}
+
+// changeEvent describes a pending row change, with copies of its rows, or returns
+// false for a row inserted and deleted again by the same transaction.
+func changeEvent(p *pendingRow) (ChangeEvent, bool) {
+	var ev ChangeEvent
+	if p.base != nil {
+		ev.Before = p.base.data
+	}
+	ev.After = p.data
+	switch {
+	case ev.Before == nil && ev.After == nil:
+		return ev, false
+	case ev.Before == nil:
+		ev.Op = ChangeInsert
+	case ev.After == nil:
+		ev.Op = ChangeDelete
+	default:
+		ev.Op = ChangeUpdate
+	}
+	ev.Before, ev.After = cloneRow(ev.Before), cloneRow(ev.After)
+	return ev, true
+}
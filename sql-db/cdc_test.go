@@ -0,0 +1,97 @@
+package sqldb
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSubscribersGetRowsOfTheirOwn(t *testing.T) {
+	db := NewDatabase()
+	if err := db.CreateTable("users", []*Column{NewColumn("id", TypeInt, PrimaryKey()), NewColumn("name", TypeString)}); err != nil {
+		t.Fatal(err)
+	}
+	const writes = 200
+	var wg sync.WaitGroup
+	for range 2 {
+		events, cancel := db.Subscribe("users", SubscribeOptions{Buffer: 2 * writes})
+		defer cancel()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2*writes; i++ {
+				ev := <-events
+				// receivers may modify the rows they get, see ChangeEvent
+				if ev.After != nil {
+					ev.After["name"] = "changed"
+				}
+				if ev.Before != nil {
+					ev.Before["name"] = "changed"
+				}
+			}
+		}()
+	}
+	for i := range writes {
+		if err := db.InsertRecord("users", map[string]any{"id": i, "name": "a"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.UpdateRecords("users", map[string]any{"id": i}, map[string]any{"name": "b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	rows, err := db.GetRecords("users", map[string]any{"name": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != writes {
+		t.Fatalf("receivers modified stored rows: %d of %d rows still have their name", len(rows), writes)
+	}
+}
+
+func TestSubscribeEventsInCommitOrder(t *testing.T) {
+	db := NewDatabase()
+	db.CreateTable("users", []*Column{NewColumn("id", TypeInt, PrimaryKey()), NewColumn("name", TypeString)})
+	events, cancel := db.Subscribe("users", SubscribeOptions{})
+	db.InsertRecord("users", map[string]any{"id": 1, "name": "a"})
+	db.UpdateRecords("users", map[string]any{"id": 1}, map[string]any{"name": "b"})
+	db.DeleteRecords("users", map[string]any{"id": 1})
+	cancel()
+
+	want := []ChangeOp{ChangeInsert, ChangeUpdate, ChangeDelete}
+	var seq uint64
+	i := 0
+	for ev := range events {
+		if i == len(want) || ev.Op != want[i] {
+			t.Fatalf("event %d is %s, want %v", i, ev.Op, want)
+		}
+		if ev.Seq <= seq {
+			t.Fatalf("event %d has sequence number %d after %d", i, ev.Seq, seq)
+		}
+		seq = ev.Seq
+		i++
+	}
+	if i != len(want) {
+		t.Fatalf("got %d events, want %d", i, len(want))
+	}
+}
+
+func TestSlowSubscriberIsClosed(t *testing.T) {
+	db := NewDatabase()
+	db.CreateTable("users", []*Column{NewColumn("id", TypeInt, PrimaryKey())})
+	events, cancel := db.Subscribe("users", SubscribeOptions{Buffer: 2})
+	defer cancel()
+	for i := range 5 {
+		// writers never wait for the receiver
+		if err := db.InsertRecord("users", map[string]any{"id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("got %d events before the channel closed, want the 2 buffered", n)
+	}
+}
@@ -0,0 +1,58 @@
+package sqldb
+
+import "fmt"
+
+// TableCheck is a named rule for whole rows, such as one column being below another.
+// Fn sees a row as it is stored, without its NULL columns, and returns why the row
+// breaks the rule.
+type TableCheck struct {
+	Name string
+	Fn   func(row map[string]any) error
+}
+
+// checkRow runs the table checks on a row whose columns are valid.
+func (t *Table) checkRow(row map[string]any) []error {
+	var violations []error
+	for _, check := range t.Checks {
+		if err := check.Fn(row); err != nil {
+			violations = append(violations, &ConstraintViolation{
+				Table:      t.Name,
+				Constraint: ConstraintCheck,
+				Name:       check.Name,
+				Detail:     err.Error(),
+			})
+		}
+	}
+	return violations
+}
+
+// validateChecks makes sure every check of a table and its columns has a function and
+// a name no other check of the table uses.
+func validateChecks(columns []*Column, checks []TableCheck) error {
+	seen := make(map[string]bool)
+	add := func(name string, fn bool) error {
+		switch {
+		case name == "":
+			return fmt.Errorf("check constraints must be named")
+		case !fn:
+			return fmt.Errorf("check %s has no function", name)
+		case seen[name]:
+			return fmt.Errorf("duplicate check %s", name)
+		}
+		seen[name] = true
+		return nil
+	}
+	for _, col := range columns {
+		for _, check := range col.Constraints.Checks {
+			if err := add(check.Name, check.Fn != nil); err != nil {
+				return fmt.Errorf("column %s: %w", col.Name, err)
+			}
+		}
+	}
+	for _, check := range checks {
+		if err := add(check.Name, check.Fn != nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func wantCheckViolation(t *testing.T, err error, name string) {
+	t.Helper()
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != ConstraintCheck || violation.Name != name {
+		t.Fatalf("got %v, want a violation of check %s", err, name)
+	}
+}
+
+func TestChecksOnInsertAndUpdate(t *testing.T) {
+	db := NewDatabase()
+	err := db.CreateTable("bookings", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("email", TypeString, Check("email", func(val any) error {
+			if !strings.Contains(val.(string), "@") {
+				return fmt.Errorf("%q is not an email address", val)
+			}
+			return nil
+		})),
+		NewColumn("start", TypeTimestamp, Required()),
+		NewColumn("end", TypeTimestamp),
+	}, TableCheck{Name: "ends_after_start", Fn: func(row map[string]any) error {
+		end, ok := row["end"].(time.Time)
+		if ok && !end.After(row["start"].(time.Time)) {
+			return fmt.Errorf("end %v is not after start", end)
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	err = db.InsertRecord("bookings", map[string]any{"id": 1, "email": "ada", "start": start})
+	wantCheckViolation(t, err, "email")
+	err = db.InsertRecord("bookings", map[string]any{"id": 1, "start": start, "end": start})
+	wantCheckViolation(t, err, "ends_after_start")
+
+	// column checks skip NULL values, and the table check a missing end
+	if err := db.InsertRecord("bookings", map[string]any{"id": 1, "start": start}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpdateRecords("bookings", nil, map[string]any{"end": start.Add(-time.Hour)}); err == nil {
+		t.Fatal("update breaking the table check succeeded")
+	}
+	if _, err := db.UpdateRecords("bookings", nil, map[string]any{"email": "ada@x", "end": start.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.UpdateRecords("bookings", nil, map[string]any{"email": "x"})
+	wantCheckViolation(t, err, "email")
+}
+
+func TestChecksInSQL(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, `CREATE TABLE users (
+		id INT PRIMARY KEY,
+		age INT CHECK (age >= 0) CHECK (age <= 150),
+		role STRING CHECK (role IN ('admin', 'user')),
+		name STRING CHECK (LENGTH(name) <= 5) CHECK (name ~ '^[a-z]+$')
+	)`)
+	mustExec(t, db, "INSERT INTO users (id, age, role, name) VALUES (1, 30, 'user', 'ada')")
+	for _, bad := range []string{
+		"INSERT INTO users (id, age) VALUES (2, -1)",
+		"INSERT INTO users (id, age) VALUES (2, 151)",
+		"INSERT INTO users (id, role) VALUES (2, 'root')",
+		"INSERT INTO users (id, name) VALUES (2, 'margaret')",
+		"INSERT INTO users (id, name) VALUES (2, 'Ada')",
+	} {
+		if _, err := db.Exec(bad); err == nil {
+			t.Errorf("%s: succeeded", bad)
+		}
+	}
+	if _, err := db.Exec("CREATE TABLE t (a INT CHECK (b >= 0))"); err == nil {
+		t.Fatal("CHECK on another column was accepted")
+	}
+}
+
+func TestCheckSchemaErrors(t *testing.T) {
+	ok := func(any) error { return nil }
+	rowOK := func(map[string]any) error { return nil }
+	tests := map[string]struct {
+		columns []*Column
+		checks  []TableCheck
+	}{
+		"unnamed":        {[]*Column{NewColumn("a", TypeInt, Check("", ok))}, nil},
+		"no function":    {[]*Column{NewColumn("a", TypeInt)}, []TableCheck{{Name: "c"}}},
+		"duplicate name": {[]*Column{NewColumn("a", TypeInt, Check("c", ok))}, []TableCheck{{Name: "c", Fn: rowOK}}},
+	}
+	for name, tt := range tests {
+		if err := NewDatabase().CreateTable("t", tt.columns, tt.checks...); err == nil {
+			t.Errorf("%s: table was created", name)
+		}
+	}
+
+	db, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.CreateTable("t", []*Column{NewColumn("a", TypeInt)}, TableCheck{Name: "c", Fn: rowOK}); err == nil {
+		t.Fatal("a persistent database accepted a Go table check")
+	}
+}
@@ -1,21 +1,58 @@
 package sqldb
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"slices"
+	"time"
 )
 
 type ColumnType string
 
 const (
-	TypeString = "string"
-	TypeInt    = "int"
+	TypeString    = "string"
+	TypeInt       = "int"
+	TypeFloat     = "float"
+	TypeBool      = "bool"
+	TypeTimestamp = "timestamp"
+	TypeBytes     = "bytes"
+	TypeJSON      = "json"
 )
 
+// timestamp formats accepted when a string is stored in a TypeTimestamp column
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
 type ColumnConstraint struct {
-	Required  bool
-	MaxLength *int
-	MinValue  *int
+	Required   bool
+	PrimaryKey bool
+	Unique     bool
+	MaxLength  *int
+	MinLength  *int
+	MinValue   *int
+	MaxValue   *int
+	Pattern    *regexp.Regexp
+	Enum       []any
+	References *ForeignKey
+	Checks     []ColumnCheck
+
+	// values generated for records being written, see AutoIncrement, Default,
+	// DefaultFunc and Computed
+	AutoIncrement bool
+	Default       any
+	DefaultFunc   func() any
+	Computed      func(row map[string]any) any
+}
+
+// ColumnCheck is a named rule for the values of a column; Fn returns why a value
+// breaks it.
+type ColumnCheck struct {
+	Name string
+	Fn   func(value any) error
 }
 
 type Column struct {
@@ -40,37 +77,157 @@ func Required() func(*ColumnConstraint) {
 	}
 }
 
+// PrimaryKey marks the column as the table's primary key, which is both required and unique.
+func PrimaryKey() func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.PrimaryKey = true
+		cc.Required = true
+		cc.Unique = true
+	}
+}
+
+// Unique rejects rows whose non-NULL value for the column is already present in the table.
+func Unique() func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.Unique = true
+	}
+}
+
 func (c *Column) Validate(value any) error {
+	_, err := c.Coerce(value)
+	return err
+}
+
+// Coerce converts value to the representation stored for the column and checks the
+// column's constraints against it. Every constraint value breaks is reported, as
+// *ConstraintViolation errors joined together.
+func (c *Column) Coerce(value any) (any, error) {
+	converted, violations := c.coerce("", value)
+	return converted, errors.Join(violations...)
+}
+
+func (c *Column) coerce(table string, value any) (any, []error) {
 	if value == nil {
 		if c.Constraints.Required {
-			return fmt.Errorf("Column %s is required", c.Name)
+			return nil, []error{c.violation(table, ConstraintRequired, nil, "value is required")}
 		}
-		return nil
+		return nil, nil
 	}
 
-	// type check
+	converted, err := c.convert(value)
+	if err != nil {
+		return nil, []error{c.violation(table, ConstraintType, value, "%v", err)}
+	}
+	if violations := c.checkConstraints(table, converted); len(violations) > 0 {
+		return nil, violations
+	}
+	return converted, nil
+}
+
+func (c *Column) violation(table, constraint string, value any, format string, args ...any) *ConstraintViolation {
+	return &ConstraintViolation{Table: table, Column: c.Name, Constraint: constraint, Value: value, Detail: fmt.Sprintf(format, args...)}
+}
+
+// convert does the type check, converting values that have an unambiguous
+// representation in the column's type (e.g. an int stored in a float column).
+func (c *Column) convert(value any) (any, error) {
 	switch c.Type {
 	case TypeString:
 		strVal, ok := value.(string)
-
 		if !ok {
-			return fmt.Errorf("expected string for column %s but got %s", c.Name, strVal)
-		}
-		if c.Constraints.MaxLength != nil && len(strVal) > *c.Constraints.MaxLength {
-			return fmt.Errorf("string length for %s exceeds max length %d", c.Name, *c.Constraints.MaxLength)
+			return nil, fmt.Errorf("expected string but got %T", value)
 		}
+		return strVal, nil
 	case TypeInt:
-		intVal, err := convertToInt(value)
+		// every integer is stored as an int64, whatever type it came in
+		v := reflect.ValueOf(value)
+		switch {
+		case v.CanInt() || v.CanUint():
+			return convertToInt(value)
+		case v.CanFloat() && v.Float() == math.Trunc(v.Float()):
+			f := v.Float()
+			if f < math.MinInt64 || f >= math.MaxInt64 {
+				return nil, fmt.Errorf("%v is out of range for int", f)
+			}
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("expected int but got %T", value)
+	case TypeFloat:
+		f, err := convertToFloat(value)
 		if err != nil {
-			return fmt.Errorf("expected int for column %s but got %T", c.Name, value)
+			return nil, fmt.Errorf("expected float but got %T", value)
 		}
-		if c.Constraints.MinValue != nil && int(intVal) < *c.Constraints.MinValue {
-			return fmt.Errorf("Min value for %s exceeds  %d", c.Name, *c.Constraints.MinValue)
+		return f, nil
+	case TypeBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool but got %T", value)
+		}
+		return b, nil
+	case TypeTimestamp:
+		ts, err := convertToTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected timestamp: %v", err)
+		}
+		return ts, nil
+	case TypeBytes:
+		b, err := convertToBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected bytes but got %T", value)
+		}
+		// the caller keeps its slice, so it must not share memory with the stored value
+		return bytes.Clone(b), nil
+	case TypeJSON:
+		raw, err := convertToJSON(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected json: %v", err)
 		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unknown column type %s", c.Type)
+}
 
+func (c *Column) checkConstraints(table string, value any) []error {
+	cc := c.Constraints
+	var violations []error
+	switch c.Type {
+	case TypeString, TypeBytes:
+		length := reflect.ValueOf(value).Len()
+		if cc.MaxLength != nil && length > *cc.MaxLength {
+			violations = append(violations, c.violation(table, ConstraintMaxLength, value, "length %d exceeds max length %d", length, *cc.MaxLength))
+		}
+		if cc.MinLength != nil && length < *cc.MinLength {
+			violations = append(violations, c.violation(table, ConstraintMinLength, value, "length %d is below min length %d", length, *cc.MinLength))
+		}
+		if strVal, ok := value.(string); ok && cc.Pattern != nil && !cc.Pattern.MatchString(strVal) {
+			violations = append(violations, c.violation(table, ConstraintPattern, value, "%q does not match pattern %s", strVal, cc.Pattern))
+		}
+	case TypeInt, TypeFloat:
+		num, _ := convertToFloat(value)
+		if cc.MinValue != nil && num < float64(*cc.MinValue) {
+			violations = append(violations, c.violation(table, ConstraintMinValue, value, "%v is below min value %d", value, *cc.MinValue))
+		}
+		if cc.MaxValue != nil && num > float64(*cc.MaxValue) {
+			violations = append(violations, c.violation(table, ConstraintMaxValue, value, "%v exceeds max value %d", value, *cc.MaxValue))
+		}
+	}
+
+	if len(cc.Enum) > 0 && !slices.ContainsFunc(cc.Enum, func(allowed any) bool {
+		cmp, ok := compareValues(value, allowed)
+		return ok && cmp == 0
+	}) {
+		violations = append(violations, c.violation(table, ConstraintEnum, value, "%v is not one of %v", value, cc.Enum))
+	}
+	for _, check := range cc.Checks {
+		if err := check.Fn(value); err != nil {
+			v := c.violation(table, ConstraintCheck, value, "%v", err)
+			v.Name = check.Name
+			violations = append(violations, v)
+		}
 	}
-	return nil
+	return violations
 }
+
 func convertToInt(val any) (int64, error) {
 	v := reflect.ValueOf(val)
 	switch v.Kind() {
@@ -78,19 +235,151 @@ func convertToInt(val any) (int64, error) {
 		return v.Int(), nil
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%d is out of range for int", v.Uint())
+		}
 		return int64(v.Uint()), nil
 	default:
 		return 0, fmt.Errorf("can not convert %T to int64", val)
 	}
 }
 
+func convertToFloat(val any) (float64, error) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	n, err := convertToInt(val)
+	if err != nil {
+		return 0, fmt.Errorf("can not convert %T to float64", val)
+	}
+	return float64(n), nil
+}
+
+func convertToTime(val any) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range timestampLayouts {
+			if ts, err := time.Parse(layout, v); err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("can not parse %q as a timestamp", v)
+	}
+	return time.Time{}, fmt.Errorf("can not convert %T to time.Time", val)
+}
+
+func convertToBytes(val any) ([]byte, error) {
+	switch v := val.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("can not convert %T to []byte", val)
+}
+
+// convertToJSON stores JSON as compact text. Strings and byte slices are taken to
+// already hold JSON text, anything else is marshalled.
+func convertToJSON(val any) (json.RawMessage, error) {
+	var text []byte
+	switch v := val.(type) {
+	case json.RawMessage:
+		text = v
+	case []byte:
+		text = v
+	case string:
+		text = []byte(v)
+	default:
+		marshalled, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return marshalled, nil
+	}
+	var decoded any
+	if err := json.Unmarshal(text, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+	compact, _ := json.Marshal(decoded)
+	return compact, nil
+}
+
 func MinValue(val int) func(*ColumnConstraint) {
 	return func(cc *ColumnConstraint) {
 		cc.MinValue = &val
 	}
 }
+func MaxValue(val int) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.MaxValue = &val
+	}
+}
 func MaxLength(length int) func(*ColumnConstraint) {
 	return func(cc *ColumnConstraint) {
 		cc.MaxLength = &length
 	}
 }
+func MinLength(length int) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.MinLength = &length
+	}
+}
+
+// Pattern requires string values to match the regular expression; it panics if expr does not compile.
+func Pattern(expr string) func(*ColumnConstraint) {
+	re := regexp.MustCompile(expr)
+	return func(cc *ColumnConstraint) {
+		cc.Pattern = re
+	}
+}
+
+// Enum restricts the column to the given set of values.
+func Enum(values ...any) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.Enum = values
+	}
+}
+
+// AutoIncrement fills the column, which must be an int, with the next number of a
+// sequence of the table whenever a record being inserted leaves it out or NULL. The
+// sequence continues after the largest value the column ever held.
+func AutoIncrement() func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.AutoIncrement = true
+	}
+}
+
+// Default fills the column with value when a record being inserted leaves it out.
+func Default(value any) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.Default = value
+	}
+}
+
+// DefaultFunc is Default with a value fn returns at every insert, e.g. the current time.
+func DefaultFunc(fn func() any) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.DefaultFunc = fn
+	}
+}
+
+// Computed derives the column from the rest of the row whenever a record is inserted or
+// updated; records can't set it themselves. fn sees the row with its defaults filled in
+// but not validated yet, and must not modify it.
+func Computed(fn func(row map[string]any) any) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.Computed = fn
+	}
+}
+
+// Check adds a rule named name for the column's non-NULL values, which fn gets as the
+// column's type converted them, e.g. float64 for a float column.
+func Check(name string, fn func(value any) error) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.Checks = append(cc.Checks, ColumnCheck{Name: name, Fn: fn})
+	}
+}
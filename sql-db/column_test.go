@@ -0,0 +1,125 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestIntColumnStoresInt64(t *testing.T) {
+	col := NewColumn("n", TypeInt)
+	for _, val := range []any{int(7), int8(7), int32(7), int64(7), uint(7), uint16(7), uint64(7), 7.0, float32(7)} {
+		got, err := col.Coerce(val)
+		if err != nil {
+			t.Errorf("Coerce(%T) = %v", val, err)
+			continue
+		}
+		if got != int64(7) {
+			t.Errorf("Coerce(%T) = %#v, want int64(7)", val, got)
+		}
+	}
+}
+
+func TestIntColumnRejectsOutOfRange(t *testing.T) {
+	col := NewColumn("n", TypeInt)
+	for _, val := range []any{uint64(math.MaxInt64) + 1, uint(math.MaxUint), 1e19, -1e19, math.Inf(1), 2.5, "7"} {
+		if got, err := col.Coerce(val); err == nil {
+			t.Errorf("Coerce(%T %v) = %v, want an error", val, val, got)
+		}
+	}
+	if got, err := col.Coerce(uint64(math.MaxInt64)); err != nil || got != int64(math.MaxInt64) {
+		t.Errorf("Coerce(MaxInt64) = %v, %v", got, err)
+	}
+}
+
+func TestIntColumnRowsShareOneType(t *testing.T) {
+	db := NewDatabase()
+	db.CreateTable("nums", []*Column{NewColumn("n", TypeInt, AutoIncrement()), NewColumn("m", TypeInt, Default(1))})
+	for _, val := range []any{int(1), uint8(2), 3.0} {
+		if err := db.InsertRecord("nums", map[string]any{"m": val}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.InsertRecord("nums", map[string]any{})
+	rows, _ := db.GetRecords("nums", nil)
+	for _, row := range rows {
+		for col, val := range row {
+			if _, ok := val.(int64); !ok {
+				t.Errorf("column %s holds %T, want int64", col, val)
+			}
+		}
+	}
+}
+
+func TestJSONColumnStoresCompactText(t *testing.T) {
+	col := NewColumn("doc", TypeJSON)
+	for _, val := range []any{` { "b": 1, "a": [1, 2] } `, []byte(`{"a":[1, 2],"b":1}`), map[string]any{"b": 1, "a": []int{1, 2}}} {
+		got, err := col.Coerce(val)
+		if err != nil {
+			t.Errorf("Coerce(%T) = %v", val, err)
+			continue
+		}
+		if raw, ok := got.(json.RawMessage); !ok || string(raw) != `{"a":[1,2],"b":1}` {
+			t.Errorf("Coerce(%T) = %#v, want compact json", val, got)
+		}
+	}
+	for _, val := range []any{`{"a":`, []byte("nope"), func() {}} {
+		if got, err := col.Coerce(val); err == nil {
+			t.Errorf("Coerce(%T %v) = %s, want an error", val, val, got)
+		}
+	}
+}
+
+func TestLengthConstraints(t *testing.T) {
+	col := NewColumn("code", TypeString, MinLength(2), MaxLength(3))
+	for val, ok := range map[string]bool{"a": false, "ab": true, "abc": true, "abcd": false} {
+		if _, err := col.Coerce(val); (err == nil) != ok {
+			t.Errorf("Coerce(%q) = %v", val, err)
+		}
+	}
+	_, err := NewColumn("key", TypeBytes, MinLength(4)).Coerce([]byte("abc"))
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != ConstraintMinLength {
+		t.Fatalf("Coerce of short bytes = %v, want a min length violation", err)
+	}
+}
+
+func TestFloatAndBoolCoercion(t *testing.T) {
+	float := NewColumn("f", TypeFloat)
+	for _, val := range []any{2, int64(2), uint8(2), float32(2), 2.0} {
+		if got, err := float.Coerce(val); err != nil || got != 2.0 {
+			t.Errorf("float Coerce(%T) = %#v, %v, want 2.0", val, got, err)
+		}
+	}
+	if got, err := float.Coerce("2"); err == nil {
+		t.Errorf("float Coerce(string) = %v, want an error", got)
+	}
+	boolean := NewColumn("b", TypeBool)
+	if got, err := boolean.Coerce(true); err != nil || got != true {
+		t.Errorf("bool Coerce(true) = %v, %v", got, err)
+	}
+	for _, val := range []any{1, "true"} {
+		if got, err := boolean.Coerce(val); err == nil {
+			t.Errorf("bool Coerce(%T) = %v, want an error", val, got)
+		}
+	}
+}
+
+func TestBytesAreCopied(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "blobs", []*Column{NewColumn("id", TypeInt, PrimaryKey()), NewColumn("data", TypeBytes)})
+	data := []byte("abc")
+	if err := db.InsertRecord("blobs", map[string]any{"id": 1, "data": data}); err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 'x'
+	row, _ := db.GetByPrimaryKey("blobs", 1)
+	if got := row["data"].([]byte); string(got) != "abc" {
+		t.Fatalf("stored bytes changed with the inserted slice: %q", got)
+	}
+	row["data"].([]byte)[0] = 'y'
+	if row, _ := db.GetByPrimaryKey("blobs", 1); string(row["data"].([]byte)) != "abc" {
+		t.Fatalf("stored bytes changed with a read row: %q", row["data"])
+	}
+}
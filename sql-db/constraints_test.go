@@ -0,0 +1,96 @@
+package sqldb
+
+import (
+	"errors"
+	"testing"
+)
+
+func wantUniqueViolation(t *testing.T, err error, column string) {
+	t.Helper()
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != ConstraintUnique || violation.Column != column {
+		t.Fatalf("got %v, want a unique violation on %s", err, column)
+	}
+}
+
+func TestPrimaryKeyRejectsDuplicates(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "accounts", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("email", TypeString, Unique()),
+	})
+	if err := db.InsertRecord("accounts", map[string]any{"id": 1, "email": "a@x"}); err != nil {
+		t.Fatal(err)
+	}
+	err := db.InsertRecord("accounts", map[string]any{"id": int64(1), "email": "b@x"})
+	wantUniqueViolation(t, err, "id")
+	if err := db.InsertRecord("accounts", map[string]any{"email": "c@x"}); err == nil {
+		t.Fatal("insert without a primary key succeeded")
+	}
+
+	if err := db.InsertRecord("accounts", map[string]any{"id": 2, "email": "b@x"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.UpdateRecords("accounts", map[string]any{"id": 2}, map[string]any{"id": 1})
+	wantUniqueViolation(t, err, "id")
+	// two rows taking the same new key in one statement collide with each other
+	_, err = db.UpdateRecords("accounts", nil, map[string]any{"id": 3})
+	wantUniqueViolation(t, err, "id")
+}
+
+func TestUniqueAllowsNulls(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "accounts", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("email", TypeString, Unique()),
+	})
+	for id := range 2 {
+		if err := db.InsertRecord("accounts", map[string]any{"id": id}); err != nil {
+			t.Fatalf("NULL email %d: %v", id, err)
+		}
+	}
+	if err := db.InsertRecord("accounts", map[string]any{"id": 2, "email": "a@x"}); err != nil {
+		t.Fatal(err)
+	}
+	err := db.InsertRecord("accounts", map[string]any{"id": 3, "email": "a@x"})
+	wantUniqueViolation(t, err, "email")
+	// a key freed by a delete can be used again
+	if _, err := db.DeleteRecords("accounts", map[string]any{"id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRecord("accounts", map[string]any{"id": 2, "email": "a@x"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetByPrimaryKey(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "accounts", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("email", TypeString, Unique()),
+	})
+	if err := db.InsertRecord("accounts", map[string]any{"id": 7, "email": "a@x"}); err != nil {
+		t.Fatal(err)
+	}
+	row, err := db.GetByPrimaryKey("accounts", 7)
+	if err != nil || row["email"] != "a@x" {
+		t.Fatalf("GetByPrimaryKey(7) = %v, %v", row, err)
+	}
+	if row, err := db.GetByPrimaryKey("accounts", 8); err != nil || row != nil {
+		t.Fatalf("GetByPrimaryKey(8) = %v, %v, want no row", row, err)
+	}
+
+	if err := db.CreateTable("log", []*Column{NewColumn("line", TypeString)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetByPrimaryKey("log", 1); err == nil {
+		t.Fatal("lookup in a table without a primary key succeeded")
+	}
+	err = db.CreateTable("pairs", []*Column{
+		NewColumn("a", TypeInt, PrimaryKey()),
+		NewColumn("b", TypeInt, PrimaryKey()),
+	})
+	if err == nil {
+		t.Fatal("table with two primary keys was created")
+	}
+}
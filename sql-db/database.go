@@ -5,70 +5,302 @@ import (
 	"sync"
 )
 
+// Database is a catalog of tables sharing one transaction manager.
+//
+// mu only guards the catalog. Schema changes take it exclusively; writers hold it
+// shared for the length of a write so a table can't be dropped under them, and
+// readers hold it just long enough to look a table up. Writers then lock the tables
+// linked by foreign keys to the one they write, in name order.
 type Database struct {
 	mu     sync.RWMutex
 	tables map[string]*Table
+	tm     *txManager
 }
 
 func NewDatabase() *Database {
-	return &Database{tables: make(map[string]*Table)}
+	return &Database{tables: make(map[string]*Table), tm: newTxManager()}
 }
 
-func (db *Database) CreateTable(name string, columns []*Column) error {
+// CreateTable adds a table with the given columns and table-level checks.
+func (db *Database) CreateTable(name string, columns []*Column, checks ...TableCheck) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if err := db.checkNewTable(name, columns, checks); err != nil {
+		return err
+	}
+	if err := db.tm.checkLoggable(columns, checks); err != nil {
+		return err
+	}
+	if err := db.checkForeignKeys(name, columns); err != nil {
+		return err
+	}
+	defs, err := encodeColumns(columns)
+	if err != nil {
+		return err
+	}
+	if err := db.tm.logRecord(&walRecord{Op: walCreateTable, Table: name, Columns: defs}); err != nil {
+		return err
+	}
+	db.createTable(name, columns, checks)
+	return nil
+}
+
+func (db *Database) checkNewTable(name string, columns []*Column, checks []TableCheck) error {
 	if _, exists := db.tables[name]; exists {
-		return fmt.Errorf("table %s already exists", name)
+		return fmt.Errorf("%w: %s", ErrTableExists, name)
 	}
-	table := NewTable(name, columns)
+	return validateSchema(columns, checks)
+}
+
+// createTable adds a table to the catalog. The caller must hold db.mu and have
+// checked the table with checkNewTable.
+func (db *Database) createTable(name string, columns []*Column, checks []TableCheck) *Table {
+	table := NewTable(name, columns, checks...)
+	table.db = db
+	table.tm = db.tm
 	db.tables[name] = table
-	fmt.Printf("table '%s' created successfully.\n", name)
-	return nil
+	return table
+}
+
+func validateSchema(columns []*Column, checks []TableCheck) error {
+	seen := make(map[string]bool)
+	primaryKeys, autoIncrements := 0, 0
+	for _, col := range columns {
+		if seen[col.Name] {
+			return fmt.Errorf("duplicate column %s", col.Name)
+		}
+		seen[col.Name] = true
+		switch col.Type {
+		case TypeString, TypeInt, TypeFloat, TypeBool, TypeTimestamp, TypeBytes, TypeJSON:
+		default:
+			return fmt.Errorf("column %s has unknown type %s", col.Name, col.Type)
+		}
+		if col.Constraints.PrimaryKey {
+			primaryKeys++
+		}
+		if err := checkGenerated(col); err != nil {
+			return err
+		}
+		if col.Constraints.AutoIncrement {
+			autoIncrements++
+		}
+	}
+	if primaryKeys > 1 {
+		return fmt.Errorf("a table can have at most one primary key, got %d", primaryKeys)
+	}
+	if autoIncrements > 1 {
+		return fmt.Errorf("a table can have at most one auto-increment column, got %d", autoIncrements)
+	}
+	return validateChecks(columns, checks)
 }
 
 func (db *Database) GetTable(name string) (*Table, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
 	table, ok := db.tables[name]
 	if !ok {
-		return table, fmt.Errorf("table %s is not found", name)
+		return table, tableNotFound(name)
 	}
 	return table, nil
 }
+
+// Tables returns the names of the tables in the database, sorted.
+func (db *Database) Tables() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return sortedNames(db.tables)
+}
+
 func (db *Database) DeleteTable(name string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	if _, ok := db.tables[name]; !ok {
-		return fmt.Errorf("table %s is not found", name)
+		return tableNotFound(name)
+	}
+	for _, ref := range db.referencing(name) {
+		if ref.table.Name != name {
+			return fmt.Errorf("table %s is referenced from %s.%s", name, ref.table.Name, ref.column.Name)
+		}
+	}
+	if err := db.tm.logRecord(&walRecord{Op: walDropTable, Table: name}); err != nil {
+		return err
 	}
-
 	delete(db.tables, name)
 	return nil
 }
-func (db *Database) GetRecords(tableName string, filter map[string]any) ([]map[string]any, error) {
+
+// writeTable runs fn with the catalog locked for reading, so the table stays in place
+// until fn returns. fn must not take the catalog lock again.
+func (db *Database) writeTable(tableName string, fn func(table *Table) error) error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	table, exists := db.tables[tableName]
 	if !exists {
-		return nil, fmt.Errorf("table %s not found", tableName)
+		return tableNotFound(tableName)
+	}
+	return fn(table)
+}
+
+func (db *Database) GetRecords(tableName string, filter map[string]any) ([]map[string]any, error) {
+	return db.GetRecordsWhere(tableName, FilterFromMap(filter))
+}
+
+// GetRecordsWhere returns the records matching pred, or every record when pred is nil.
+func (db *Database) GetRecordsWhere(tableName string, pred Predicate) ([]map[string]any, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.checkPredicate(pred); err != nil {
+		return nil, err
+	}
+
+	return table.GetRowsWhere(pred), nil
+
+}
+
+// Select reads a table as described by opts, see QueryOptions.
+func (db *Database) Select(tableName string, opts QueryOptions) (*ResultSet, error) {
+	rs, _, err := db.query(tableName, opts, false)
+	return rs, err
+}
+
+func (db *Database) explain(tableName string, opts QueryOptions) (*PlanNode, error) {
+	_, plan, err := db.query(tableName, opts, true)
+	return plan, err
+}
+
+func (db *Database) query(tableName string, opts QueryOptions, explain bool) (*ResultSet, *PlanNode, error) {
+	lookup := func(name string) (source, error) {
+		table, ok := db.tables[name]
+		if !ok {
+			return source{}, tableNotFound(name)
+		}
+		return source{table: table}, nil
+	}
+	db.mu.RLock()
+	from, err := lookup(tableName)
+	var joined []source
+	if err == nil {
+		joined, err = resolveJoins(opts.Joins, lookup)
+	}
+	db.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return table.GetRows(filter), nil
+	snapshot := db.tm.acquireSnapshot()
+	defer db.tm.releaseSnapshot(snapshot)
+	return runQuery(snapshot, from, joined, opts, explain)
+}
 
+func (db *Database) columns(tableName string) ([]*Column, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return table.Columns, nil
+}
+
+// insertRows writes through the table, which takes the catalog lock itself.
+func (db *Database) insertRows(tableName string, records []map[string]any) (int64, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return table.addRows(records)
 }
 
 func (db *Database) InsertRecord(tableName string, record map[string]any) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	_, err := db.insertRows(tableName, []map[string]any{record})
+	return err
+}
 
-	table, exists := db.tables[tableName]
-	if !exists {
-		return fmt.Errorf("table %s not found", tableName)
+// InsertReturningID is InsertRecord, also returning the value generated for the
+// table's AutoIncrement column, or the value the record gave it. It returns 0 for a
+// table without one.
+func (db *Database) InsertReturningID(tableName string, record map[string]any) (int64, error) {
+	return db.insertRows(tableName, []map[string]any{record})
+}
+
+// GetByPrimaryKey looks a record up through the primary key index. It returns nil when no record has that key.
+func (db *Database) GetByPrimaryKey(tableName string, key any) (map[string]any, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return nil, err
 	}
 
-	return table.AddRow(record)
+	return table.GetByPrimaryKey(key)
+}
+
+func (db *Database) UpdateRecords(tableName string, filter map[string]any, changes map[string]any) (int, error) {
+	return db.UpdateRecordsWhere(tableName, FilterFromMap(filter), changes)
+}
+
+// UpdateRecordsWhere applies changes to every record matching pred and returns the number of records updated.
+func (db *Database) UpdateRecordsWhere(tableName string, pred Predicate, changes map[string]any) (int, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if err := table.checkPredicate(pred); err != nil {
+		return 0, err
+	}
+	return table.UpdateRowsWhere(pred, changes)
+}
+
+func (db *Database) DeleteRecords(tableName string, filter map[string]any) (int, error) {
+	return db.DeleteRecordsWhere(tableName, FilterFromMap(filter))
+}
+
+// DeleteRecordsWhere removes every record matching pred and returns the number of records deleted.
+// Rows referencing them are deleted, set to NULL or make the delete fail, as their
+// foreign keys specify.
+func (db *Database) DeleteRecordsWhere(tableName string, pred Predicate) (int, error) {
+	table, err := db.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if err := table.checkPredicate(pred); err != nil {
+		return 0, err
+	}
+	return table.DeleteRowsWhere(pred)
+}
+
+// CreateIndex indexes a column of a table; lookups on that column then avoid a full scan.
+func (db *Database) CreateIndex(tableName, column string, kind IndexKind) error {
+	return db.writeTable(tableName, func(table *Table) error {
+		if err := table.CreateIndex(column, kind); err != nil {
+			return err
+		}
+		// indexes are derived data, so logging one after building it is safe
+		err := db.tm.logRecord(&walRecord{Op: walCreateIndex, Table: tableName, Column: column, Kind: kind})
+		if err != nil {
+			table.DropIndex(column)
+		}
+		return err
+	})
+}
+
+func (db *Database) DropIndex(tableName, column string) error {
+	return db.writeTable(tableName, func(table *Table) error {
+		if err := table.DropIndex(column); err != nil {
+			return err
+		}
+		return db.tm.logRecord(&walRecord{Op: walDropIndex, Table: tableName, Column: column})
+	})
+}
+
+// Vacuum drops row versions that no active snapshot or transaction can see any more.
+// Tables are also vacuumed automatically once most of their versions are dead.
+func (db *Database) Vacuum() {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, table := range db.tables {
+		table.Vacuum()
+	}
 }
@@ -0,0 +1,62 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the database wrap these, so they can be told apart with errors.Is.
+var (
+	ErrTableNotFound = errors.New("table not found")
+	ErrTableExists   = errors.New("table already exists")
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Constraints a ConstraintViolation can name.
+const (
+	ConstraintType       = "type"
+	ConstraintRequired   = "not null"
+	ConstraintUnique     = "unique"
+	ConstraintMinLength  = "min length"
+	ConstraintMaxLength  = "max length"
+	ConstraintPattern    = "pattern"
+	ConstraintMinValue   = "min value"
+	ConstraintMaxValue   = "max value"
+	ConstraintEnum       = "enum"
+	ConstraintForeignKey = "foreign key"
+	ConstraintCheck      = "check"
+)
+
+// ConstraintViolation is a value rejected by the type or a constraint of a column.
+// A write that breaks several constraints of a row returns all of them, joined with
+// errors.Join; errors.As finds the first.
+type ConstraintViolation struct {
+	Table      string // empty when a column is checked on its own, see Column.Validate
+	Column     string // empty for a TableCheck
+	Constraint string // one of the Constraint names above
+	Name       string // name of the check, for ConstraintCheck
+	Value      any    // the rejected value, nil for a missing one or a whole row
+	Detail     string // what was expected of the value
+}
+
+func (v *ConstraintViolation) Error() string {
+	target := v.Table
+	if v.Column != "" && target != "" {
+		target += "." + v.Column
+	} else if v.Column != "" {
+		target = v.Column
+	}
+	constraint := v.Constraint + " constraint"
+	if v.Name != "" {
+		constraint += " " + v.Name
+	}
+	return fmt.Sprintf("%s violates %s: %s", target, constraint, v.Detail)
+}
+
+func tableNotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrTableNotFound, name)
+}
+
+func unknownColumn(name string) error {
+	return fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+}
@@ -0,0 +1,84 @@
+package sqldb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	checks := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"GetTable", func() error { _, err := db.GetTable("nope"); return err }(), ErrTableNotFound},
+		{"InsertRecord", db.InsertRecord("nope", map[string]any{"id": 1}), ErrTableNotFound},
+		{"DeleteTable", db.DeleteTable("nope"), ErrTableNotFound},
+		{"Exec", func() error { _, err := db.Exec("SELECT * FROM nope"); return err }(), ErrTableNotFound},
+		{"CreateTable", db.CreateTable("users", []*Column{NewColumn("id", TypeInt)}), ErrTableExists},
+		{"InsertRecord column", db.InsertRecord("users", map[string]any{"id": 1, "name": "ada", "nope": 1}), ErrUnknownColumn},
+		{"GetRecords", func() error { _, err := db.GetRecords("users", map[string]any{"nope": 1}); return err }(), ErrUnknownColumn},
+		{"UpdateRecords", func() error { _, err := db.UpdateRecords("users", nil, map[string]any{"nope": 1}); return err }(), ErrUnknownColumn},
+	}
+	for _, c := range checks {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, c.err, c.want)
+		}
+	}
+}
+
+func TestInsertReportsEveryViolation(t *testing.T) {
+	db := NewDatabase()
+	err := db.CreateTable("people", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("name", TypeString, MaxLength(3), Pattern("^[a-z]+$")),
+		NewColumn("age", TypeInt, MinValue(0)),
+		NewColumn("role", TypeString, Enum("admin", "user")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertRecord("people", map[string]any{"name": "Grace", "age": -1, "role": "root"})
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %v, want the violations joined", err)
+	}
+	var got []string
+	var violation *ConstraintViolation
+	for _, e := range joined.Unwrap() {
+		if !errors.As(e, &violation) {
+			t.Fatalf("%v is not a ConstraintViolation", e)
+		}
+		if violation.Table != "people" {
+			t.Errorf("%v names table %q", violation, violation.Table)
+		}
+		got = append(got, violation.Column+" "+violation.Constraint)
+	}
+	want := []string{"id not null", "name max length", "name pattern", "age min value", "role enum"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("violations = %v, want %v", got, want)
+	}
+	// errors.As finds the first
+	if !errors.As(err, &violation) || violation.Column != "id" {
+		t.Fatalf("errors.As found %v, want the id violation", violation)
+	}
+}
+
+func TestValidateNamesTheOffendingType(t *testing.T) {
+	err := NewColumn("age", TypeInt).Validate("ten")
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != ConstraintType || violation.Value != "ten" {
+		t.Fatalf("got %#v, want a type violation for \"ten\"", err)
+	}
+	if !strings.Contains(err.Error(), "string") {
+		t.Fatalf("%q doesn't name the type given", err)
+	}
+	if err := NewColumn("age", TypeInt).Validate(10); err != nil {
+		t.Fatalf("valid value rejected: %v", err)
+	}
+}
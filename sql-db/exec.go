@@ -0,0 +1,240 @@
+package sqldb
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Result struct {
+	RowsAffected int
+	LastInsertID int64 // value generated for the AutoIncrement column of the last row inserted
+}
+
+type ResultSet struct {
+	Columns []string
+	Rows    []map[string]any
+	Cursor  string // set when Limit cut the rows of a non-aggregate read short; pass it as QueryOptions.After for the next page
+}
+
+// recordStore is what DML statements run against: the database itself, or a transaction.
+type recordStore interface {
+	columns(tableName string) ([]*Column, error)
+	insertRows(tableName string, records []map[string]any) (int64, error)
+	GetRecordsWhere(tableName string, pred Predicate) ([]map[string]any, error)
+	Select(tableName string, opts QueryOptions) (*ResultSet, error)
+	explain(tableName string, opts QueryOptions) (*PlanNode, error)
+	UpdateRecordsWhere(tableName string, pred Predicate, changes map[string]any) (int, error)
+	DeleteRecordsWhere(tableName string, pred Predicate) (int, error)
+}
+
+// Exec parses and runs every statement in query, returning the rows affected by the last one.
+// args fill the query's ? placeholders, see Parse.
+func (db *Database) Exec(query string, args ...any) (Result, error) {
+	stmts, err := Parse(query, args...)
+	if err != nil {
+		return Result{}, err
+	}
+	var res Result
+	for _, stmt := range stmts {
+		if res, err = db.execStatement(stmt); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Query runs a single SELECT statement. An EXPLAIN SELECT statement returns the plan
+// in a single "plan" column, one row per step.
+func (db *Database) Query(query string, args ...any) (*ResultSet, error) {
+	stmt, err := parseQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return execQuery(db, stmt)
+}
+
+// Explain runs a single SELECT statement and returns the plan it followed, with the
+// rows each step estimated and actually produced.
+func (db *Database) Explain(query string, args ...any) (*PlanNode, error) {
+	stmt, err := parseExplain(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return db.explain(stmt.Table, selectOptions(stmt))
+}
+
+func (db *Database) execStatement(stmt Statement) (Result, error) {
+	switch s := stmt.(type) {
+	case *CreateTableStmt:
+		return Result{}, db.CreateTable(s.Table, s.Columns)
+	case *DropTableStmt:
+		return Result{}, db.DeleteTable(s.Table)
+	case *CreateIndexStmt:
+		return Result{}, db.CreateIndex(s.Table, s.Column, s.Kind)
+	case *DropIndexStmt:
+		return Result{}, db.DropIndex(s.Table, s.Column)
+	case *AlterTableStmt:
+		return Result{}, db.AlterTable(s.Table, s.Alterations...)
+	}
+	return execDML(db, stmt)
+}
+
+// Exec runs DML statements inside the transaction. Schema changes are not transactional
+// and have to go through Database.Exec.
+func (tx *Tx) Exec(query string, args ...any) (Result, error) {
+	stmts, err := Parse(query, args...)
+	if err != nil {
+		return Result{}, err
+	}
+	var res Result
+	for _, stmt := range stmts {
+		if res, err = execDML(tx, stmt); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Query runs a single SELECT or EXPLAIN statement, seeing the transaction's own writes.
+func (tx *Tx) Query(query string, args ...any) (*ResultSet, error) {
+	stmt, err := parseQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return execQuery(tx, stmt)
+}
+
+// Explain is Database.Explain inside the transaction.
+func (tx *Tx) Explain(query string, args ...any) (*PlanNode, error) {
+	stmt, err := parseExplain(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return tx.explain(stmt.Table, selectOptions(stmt))
+}
+
+func parseQuery(query string, args []any) (Statement, error) {
+	stmts, err := Parse(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("Query expects a single statement, got %d", len(stmts))
+	}
+	switch stmts[0].(type) {
+	case *SelectStmt, *ExplainStmt:
+		return stmts[0], nil
+	}
+	return nil, fmt.Errorf("Query expects a SELECT statement, use Exec instead")
+}
+
+// parseExplain accepts a SELECT statement, with or without EXPLAIN.
+func parseExplain(query string, args []any) (*SelectStmt, error) {
+	stmt, err := parseQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if explain, ok := stmt.(*ExplainStmt); ok {
+		return explain.Select, nil
+	}
+	return stmt.(*SelectStmt), nil
+}
+
+func execQuery(store recordStore, stmt Statement) (*ResultSet, error) {
+	if explain, ok := stmt.(*ExplainStmt); ok {
+		return execExplain(store, explain)
+	}
+	return execSelect(store, stmt.(*SelectStmt))
+}
+
+func execDML(store recordStore, stmt Statement) (Result, error) {
+	switch s := stmt.(type) {
+	case *InsertStmt:
+		return execInsert(store, s)
+	case *SelectStmt:
+		rs, err := execSelect(store, s)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{RowsAffected: len(rs.Rows)}, nil
+	case *ExplainStmt:
+		rs, err := execExplain(store, s)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{RowsAffected: len(rs.Rows)}, nil
+	case *UpdateStmt:
+		n, err := store.UpdateRecordsWhere(s.Table, s.Where, s.Set)
+		return Result{RowsAffected: n}, err
+	case *DeleteStmt:
+		n, err := store.DeleteRecordsWhere(s.Table, s.Where)
+		return Result{RowsAffected: n}, err
+	case *CreateTableStmt, *DropTableStmt, *CreateIndexStmt, *DropIndexStmt, *AlterTableStmt:
+		return Result{}, fmt.Errorf("schema changes are not supported inside a transaction")
+	}
+	return Result{}, fmt.Errorf("unsupported statement %T", stmt)
+}
+
+func execInsert(store recordStore, stmt *InsertStmt) (Result, error) {
+	columns := stmt.Columns
+	if len(columns) == 0 {
+		tableColumns, err := store.columns(stmt.Table)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, col := range tableColumns {
+			columns = append(columns, col.Name)
+		}
+	}
+
+	// insertRows validates every row up front so a multi-row insert is all or nothing
+	records := make([]map[string]any, 0, len(stmt.Values))
+	for _, values := range stmt.Values {
+		if len(values) != len(columns) {
+			return Result{}, fmt.Errorf("expected %d values but got %d", len(columns), len(values))
+		}
+		// an explicit NULL is kept, so that only columns left out get their default
+		record := make(map[string]any, len(columns))
+		for i, col := range columns {
+			record[col] = values[i]
+		}
+		records = append(records, record)
+	}
+	id, err := store.insertRows(stmt.Table, records)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{RowsAffected: len(records), LastInsertID: id}, nil
+}
+
+func execSelect(store recordStore, stmt *SelectStmt) (*ResultSet, error) {
+	return store.Select(stmt.Table, selectOptions(stmt))
+}
+
+func execExplain(store recordStore, stmt *ExplainStmt) (*ResultSet, error) {
+	plan, err := store.explain(stmt.Select.Table, selectOptions(stmt.Select))
+	if err != nil {
+		return nil, err
+	}
+	rs := &ResultSet{Columns: []string{"plan"}}
+	for _, line := range strings.Split(strings.TrimSuffix(plan.String(), "\n"), "\n") {
+		rs.Rows = append(rs.Rows, map[string]any{"plan": line})
+	}
+	return rs, nil
+}
+
+func selectOptions(stmt *SelectStmt) QueryOptions {
+	return QueryOptions{
+		Columns: stmt.Columns,
+		Where:   stmt.Where,
+		OrderBy: stmt.OrderBy,
+		Limit:   stmt.Limit,
+		Offset:  stmt.Offset,
+
+		GroupBy:    stmt.GroupBy,
+		Aggregates: stmt.Aggregates,
+		Having:     stmt.Having,
+
+		Joins: stmt.Joins,
+	}
+}
@@ -0,0 +1,76 @@
+package sqldb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExecStatements(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, `
+		CREATE TABLE users (id INT PRIMARY KEY, username VARCHAR(20) NOT NULL);
+		INSERT INTO users (id, username) VALUES (1, 'ada'), (2, 'linus'), (3, 'grace');
+	`)
+	if res := mustExec(t, db, "UPDATE users SET username = 'bob' WHERE id = 2"); res.RowsAffected != 1 {
+		t.Fatalf("UPDATE affected %d rows, want 1", res.RowsAffected)
+	}
+	if res := mustExec(t, db, "DELETE FROM users WHERE id = 3"); res.RowsAffected != 1 {
+		t.Fatalf("DELETE affected %d rows, want 1", res.RowsAffected)
+	}
+
+	rs := mustQuery(t, db, "SELECT username FROM users WHERE id = 2")
+	if len(rs.Columns) != 1 || rs.Columns[0] != "username" {
+		t.Fatalf("columns = %v, want [username]", rs.Columns)
+	}
+	if len(rs.Rows) != 1 || rs.Rows[0]["username"] != "bob" {
+		t.Fatalf("rows = %v, want bob", rs.Rows)
+	}
+	if n := len(mustQuery(t, db, "SELECT * FROM users").Rows); n != 2 {
+		t.Fatalf("got %d rows, want 2", n)
+	}
+	if n := len(mustQuery(t, db, "SELECT * FROM users LIMIT 1").Rows); n != 1 {
+		t.Fatalf("LIMIT 1 returned %d rows", n)
+	}
+	if rs := mustQuery(t, db, "SELECT * FROM users LIMIT 0"); len(rs.Rows) != 0 || rs.Cursor != "" {
+		t.Fatalf("LIMIT 0 returned %d rows and cursor %q, want none", len(rs.Rows), rs.Cursor)
+	}
+}
+
+func TestExecRejectsBadStatements(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, "CREATE TABLE users (id INT PRIMARY KEY, username VARCHAR(3))")
+	for _, tc := range []struct{ query, want string }{
+		{"SELEC * FROM users", "syntax error at position 0"},
+		{"SELECT * FROM users WHERE", "end of query"},
+		{"INSERT INTO users (id, username) VALUES (1)", "expected 2 values"},
+		{"INSERT INTO users (id, nope) VALUES (2, 'x')", "unknown column"},
+		{"INSERT INTO users (id, username) VALUES (2, 'linus')", "max length"},
+		{"SELECT * FROM missing", "table not found"},
+		{"SELECT * FROM users WHERE id = ?", "no argument for placeholder 1"},
+		{"CREATE TABLE items (price FLOAT CHECK (price >= 1.5))", "CHECK bounds must be integers"},
+	} {
+		if _, err := db.Exec(tc.query); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s = %v, want an error containing %q", tc.query, err, tc.want)
+		}
+	}
+}
+
+func TestExecIntLiteralsAreInt64(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, "CREATE TABLE items (price FLOAT CHECK (price >= -2) CHECK (price <= 10))")
+	mustExec(t, db, "INSERT INTO items (price) VALUES (-2), (2.5)")
+	if _, err := db.Exec("INSERT INTO items (price) VALUES (10.5)"); err == nil {
+		t.Fatal("a price above the CHECK bound was accepted")
+	}
+	rs := mustQuery(t, db, "SELECT * FROM items WHERE price > -3 AND price < 3")
+	if len(rs.Rows) != 2 {
+		t.Fatalf("got %v, want both items", rs.Rows)
+	}
+	stmts, err := Parse("SELECT * FROM items WHERE price = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := stmts[0].(*SelectStmt).Where.(*Comparison).Value; value != int64(2) {
+		t.Fatalf("literal 2 parsed as %T, want int64", value)
+	}
+}
@@ -0,0 +1,265 @@
+package sqldb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReferenceAction is what happens to referencing rows when the row they reference is deleted.
+type ReferenceAction string
+
+const (
+	Restrict ReferenceAction = "RESTRICT" // the delete fails
+	Cascade  ReferenceAction = "CASCADE"  // referencing rows are deleted too
+	SetNull  ReferenceAction = "SET NULL" // the referencing column is set to NULL
+)
+
+// ForeignKey makes a column reference the primary key of a table.
+type ForeignKey struct {
+	Table    string
+	OnDelete ReferenceAction
+}
+
+// References adds a foreign key: non-NULL values of the column must be a primary key
+// of table. Changing a referenced primary key is always rejected.
+func References(table string, onDelete ReferenceAction) func(*ColumnConstraint) {
+	return func(cc *ColumnConstraint) {
+		cc.References = &ForeignKey{Table: table, OnDelete: onDelete}
+	}
+}
+
+// reference is a foreign key column, seen from the table it references.
+type reference struct {
+	table  *Table
+	column *Column
+}
+
+// checkForeignKeys validates the foreign keys of a table that is being created or
+// altered. The caller must hold db.mu.
+func (db *Database) checkForeignKeys(name string, columns []*Column) error {
+	for _, col := range columns {
+		fk := col.Constraints.References
+		if fk == nil {
+			continue
+		}
+		parentColumns := columns
+		if fk.Table != name {
+			parent, ok := db.tables[fk.Table]
+			if !ok {
+				return fmt.Errorf("column %s references unknown table %s", col.Name, fk.Table)
+			}
+			parentColumns = parent.Columns
+		}
+		pk := primaryKey(parentColumns)
+		if pk == nil {
+			return fmt.Errorf("column %s references table %s, which has no primary key", col.Name, fk.Table)
+		}
+		if pk.Type != col.Type {
+			return fmt.Errorf("column %s is %s but references %s.%s, which is %s", col.Name, col.Type, fk.Table, pk.Name, pk.Type)
+		}
+		switch fk.OnDelete {
+		case Restrict, Cascade:
+		case SetNull:
+			if col.Constraints.Required {
+				return fmt.Errorf("column %s is required and can not be SET NULL on delete", col.Name)
+			}
+		default:
+			return fmt.Errorf("unknown ON DELETE action %s for column %s", fk.OnDelete, col.Name)
+		}
+	}
+	return nil
+}
+
+// referencing lists the foreign key columns that reference the table called name.
+// The caller must hold db.mu.
+func (db *Database) referencing(name string) []reference {
+	var refs []reference
+	for _, tableName := range sortedNames(db.tables) {
+		table := db.tables[tableName]
+		for _, col := range table.Columns {
+			if fk := col.Constraints.References; fk != nil && fk.Table == name {
+				refs = append(refs, reference{table: table, column: col})
+			}
+		}
+	}
+	return refs
+}
+
+// fkGroup returns the tables linked to the given ones by foreign keys in either
+// direction, which a write to them may have to read or cascade into. The caller must
+// hold db.mu.
+func (db *Database) fkGroup(tables ...*Table) []*Table {
+	seen := make(map[string]*Table)
+	queue := append([]*Table(nil), tables...)
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if seen[t.Name] != nil {
+			continue
+		}
+		seen[t.Name] = t
+		for _, col := range t.Columns {
+			if fk := col.Constraints.References; fk != nil && db.tables[fk.Table] != nil {
+				queue = append(queue, db.tables[fk.Table])
+			}
+		}
+		for _, ref := range db.referencing(t.Name) {
+			queue = append(queue, ref.table)
+		}
+	}
+	group := make([]*Table, 0, len(seen))
+	for _, t := range seen {
+		group = append(group, t)
+	}
+	return group
+}
+
+// lockTables takes the write locks of tables in name order, so writers can't deadlock,
+// and returns a function releasing them.
+func lockTables(tables []*Table) func() {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	for _, t := range tables {
+		t.mu.Lock()
+	}
+	return func() {
+		for _, t := range tables {
+			t.mu.Unlock()
+		}
+	}
+}
+
+// checkParents makes sure the given rows of t, which tx has written, only reference
+// rows that exist in the snapshot plus tx's changes.
+func (tx *Tx) checkParents(snapshot uint64, t *Table, ids []int64) error {
+	if tx.db == nil {
+		return nil
+	}
+	ws := tx.writes[t.Name]
+	for _, col := range t.Columns {
+		fk := col.Constraints.References
+		if fk == nil {
+			continue
+		}
+		parent := tx.db.tables[fk.Table]
+		pk := parent.PrimaryKey()
+		checked := make(map[any]bool)
+		for _, id := range ids {
+			val := ws.rows[id].data[col.Name]
+			if val == nil || checked[normalizeKey(val)] {
+				continue
+			}
+			if len(parent.scan(snapshot, Eq(pk.Name, val), tx.writes[parent.Name])) == 0 {
+				return missingParent(t, col, val, parent)
+			}
+			checked[normalizeKey(val)] = true
+		}
+	}
+	return nil
+}
+
+// checkNotReferenced fails if a row visible in the snapshot plus tx's changes references
+// one of the given primary keys of t.
+func (tx *Tx) checkNotReferenced(snapshot uint64, t *Table, keys []any) error {
+	if tx.db == nil || len(keys) == 0 {
+		return nil
+	}
+	for _, ref := range tx.db.referencing(t.Name) {
+		found := ref.table.scan(snapshot, In(ref.column.Name, keys...), tx.writes[ref.table.Name])
+		if len(found) > 0 {
+			val := found[0].data[ref.column.Name]
+			return ref.column.violation(ref.table.Name, ConstraintForeignKey, val,
+				"%v is still referenced, so %s.%s can't be deleted or changed", val, t.Name, t.PrimaryKey().Name)
+		}
+	}
+	return nil
+}
+
+// onParentsDeleted applies the ON DELETE action of every foreign key referencing t to
+// the rows referencing the deleted primary keys.
+func (tx *Tx) onParentsDeleted(t *Table, keys []any) error {
+	if tx.db == nil || len(keys) == 0 {
+		return nil
+	}
+	for _, ref := range tx.db.referencing(t.Name) {
+		pred := In(ref.column.Name, keys...)
+		switch ref.column.Constraints.References.OnDelete {
+		case Cascade:
+			if _, err := tx.deleteWhere(ref.table, pred); err != nil {
+				return err
+			}
+		case SetNull:
+			if _, err := tx.updateWhere(ref.table, pred, map[string]any{ref.column.Name: nil}); err != nil {
+				return err
+			}
+		}
+	}
+	// whatever RESTRICT protects, or a cascade could not remove, is still referenced
+	return tx.checkNotReferenced(tx.snapshot, t, keys)
+}
+
+// checkForeignKeysAtCommit repeats the foreign key checks against the latest commit,
+// which may have changed since the transaction's snapshot. The caller holds the write
+// locks of every table involved.
+func (tx *Tx) checkForeignKeysAtCommit() error {
+	if tx.db == nil {
+		return nil
+	}
+	latest := tx.db.tm.committed.Load()
+	for _, name := range sortedNames(tx.writes) {
+		ws := tx.writes[name]
+		t := ws.table
+		var written []int64
+		var removed []any
+		pk := t.PrimaryKey()
+		for _, id := range sortedIDs(ws.rows) {
+			p := ws.rows[id]
+			if p.data != nil {
+				written = append(written, id)
+			}
+			if pk != nil && p.base != nil {
+				if old := p.base.data[pk.Name]; p.data == nil || !sameKey(old, p.data[pk.Name]) {
+					removed = append(removed, old)
+				}
+			}
+		}
+		if err := tx.checkParents(latest, t, written); err != nil {
+			return err
+		}
+		if err := tx.checkNotReferenced(latest, t, removed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameKey(a, b any) bool {
+	return normalizeKey(a) == normalizeKey(b)
+}
+
+func missingParent(t *Table, col *Column, val any, parent *Table) error {
+	return col.violation(t.Name, ConstraintForeignKey, val, "%v has no matching row in %s", val, parent.Name)
+}
+
+// checkReferencedRows makes sure every current row of t satisfies its foreign keys,
+// e.g. after an alteration or loading a snapshot. The caller must hold db.mu.
+func (db *Database) checkReferencedRows(t *Table) error {
+	snapshot := db.tm.committed.Load()
+	for _, col := range t.Columns {
+		fk := col.Constraints.References
+		if fk == nil {
+			continue
+		}
+		parent := db.tables[fk.Table]
+		if fk.Table == t.Name {
+			parent = t
+		}
+		pk := parent.PrimaryKey()
+		for _, ref := range t.scan(snapshot, NotNull(col.Name), nil) {
+			val := ref.data[col.Name]
+			if len(parent.scan(snapshot, Eq(pk.Name, val), nil)) == 0 {
+				return missingParent(t, col, val, parent)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// checkGenerated makes sure the values a column generates can be stored in it.
+func checkGenerated(col *Column) error {
+	cc := col.Constraints
+	switch {
+	case cc.AutoIncrement && col.Type != TypeInt:
+		return fmt.Errorf("auto-increment column %s must be an int", col.Name)
+	case cc.Computed != nil && (cc.AutoIncrement || cc.Default != nil || cc.DefaultFunc != nil):
+		return fmt.Errorf("computed column %s can't have a default", col.Name)
+	case cc.Default != nil:
+		if _, err := col.Coerce(cc.Default); err != nil {
+			return fmt.Errorf("default of column %s: %w", col.Name, err)
+		}
+	}
+	return nil
+}
+
+// generate returns a copy of a record being inserted with its auto-increment value,
+// defaults and computed columns filled in.
+func (t *Table) generate(r map[string]any) (map[string]any, error) {
+	return t.generateWith(r, func() int64 { return t.autoInc.Add(1) })
+}
+
+// generateWith is generate taking auto-increment values from nextID, so that imports
+// can check a record without using up the sequence.
+func (t *Table) generateWith(r map[string]any, nextID func() int64) (map[string]any, error) {
+	row := maps.Clone(r)
+	if row == nil {
+		row = make(map[string]any)
+	}
+	for _, col := range t.Columns {
+		cc := col.Constraints
+		_, given := row[col.Name]
+		switch {
+		case cc.Computed != nil && given:
+			return nil, fmt.Errorf("column %s is computed and can't be set", col.Name)
+		case cc.AutoIncrement && row[col.Name] == nil:
+			row[col.Name] = nextID()
+		case !given && cc.DefaultFunc != nil:
+			row[col.Name] = cc.DefaultFunc()
+		case !given && cc.Default != nil:
+			row[col.Name] = copyValue(cc.Default)
+		}
+	}
+	t.compute(row)
+	return row, nil
+}
+
+// compute sets the computed columns of row from the rest of it.
+func (t *Table) compute(row map[string]any) {
+	for _, col := range t.Columns {
+		if fn := col.Constraints.Computed; fn != nil {
+			if val := fn(row); val != nil {
+				row[col.Name] = val
+			} else {
+				delete(row, col.Name)
+			}
+		}
+	}
+}
+
+// recompute sets the computed columns of a stored row, converting their values.
+func (t *Table) recompute(row map[string]any) error {
+	t.compute(row)
+	var errs []error
+	for _, col := range t.Columns {
+		if col.Constraints.Computed == nil {
+			continue
+		}
+		val, violations := col.coerce(t.Name, row[col.Name])
+		errs = append(errs, violations...)
+		if val != nil {
+			row[col.Name] = val
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Table) autoIncrementColumn() *Column {
+	for _, col := range t.Columns {
+		if col.Constraints.AutoIncrement {
+			return col
+		}
+	}
+	return nil
+}
+
+// observeAutoIncrement moves the table's sequence past the value row holds, so values
+// stored explicitly are not generated again.
+func (t *Table) observeAutoIncrement(row map[string]any) {
+	col := t.autoIncrementColumn()
+	if col == nil {
+		return
+	}
+	n, err := convertToInt(row[col.Name])
+	if err != nil {
+		return
+	}
+	for {
+		current := t.autoInc.Load()
+		if n <= current || t.autoInc.CompareAndSwap(current, n) {
+			return
+		}
+	}
+}
+
+// lastInsertID is the auto-increment value of the last of the rows inserted with ids,
+// 0 if the table has no auto-increment column.
+func (ws *writeSet) lastInsertID(ids []int64) int64 {
+	col := ws.table.autoIncrementColumn()
+	if col == nil || len(ids) == 0 {
+		return 0
+	}
+	n, _ := convertToInt(ws.rows[ids[len(ids)-1]].data[col.Name])
+	return n
+}
@@ -0,0 +1,110 @@
+package sqldb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoIncrement(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "orders", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey(), AutoIncrement()),
+		NewColumn("qty", TypeInt, Required()),
+	})
+	for want := int64(1); want <= 2; want++ {
+		if id, err := db.InsertReturningID("orders", map[string]any{"qty": 1}); err != nil || id != want {
+			t.Fatalf("InsertReturningID = %d, %v, want %d", id, err, want)
+		}
+	}
+	// an explicit value moves the sequence past it
+	if err := db.InsertRecord("orders", map[string]any{"id": 10, "qty": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := db.InsertReturningID("orders", map[string]any{"id": nil, "qty": 1}); id != 11 {
+		t.Fatalf("id after an explicit 10 = %d, want 11", id)
+	}
+	res := mustExec(t, db, "INSERT INTO orders (qty) VALUES (1), (2)")
+	if res.LastInsertID != 13 {
+		t.Fatalf("LastInsertID = %d, want 13", res.LastInsertID)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	db := NewDatabase()
+	clock := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	createTable(t, db, "orders", []*Column{
+		NewColumn("status", TypeString, Default("new")),
+		NewColumn("created", TypeTimestamp, DefaultFunc(func() any {
+			clock = clock.Add(time.Hour)
+			return clock
+		})),
+		NewColumn("qty", TypeInt, Required()),
+	})
+	db.InsertRecord("orders", map[string]any{"qty": 1})
+	db.InsertRecord("orders", map[string]any{"qty": 1, "status": "paid"})
+	db.InsertRecord("orders", map[string]any{"qty": 1, "status": nil})
+
+	rows, _ := db.GetRecords("orders", nil)
+	if rows[0]["status"] != "new" || rows[1]["status"] != "paid" || rows[2]["status"] != nil {
+		t.Fatalf("statuses = %v, %v, %v, want new, paid and NULL", rows[0]["status"], rows[1]["status"], rows[2]["status"])
+	}
+	first, second := rows[0]["created"].(time.Time), rows[1]["created"].(time.Time)
+	if !second.After(first) {
+		t.Fatalf("DefaultFunc was not called for every insert: %v, %v", first, second)
+	}
+}
+
+func TestComputedColumns(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "orders", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey(), AutoIncrement()),
+		NewColumn("qty", TypeInt, Required()),
+		NewColumn("price", TypeFloat),
+		NewColumn("total", TypeFloat, Computed(func(row map[string]any) any {
+			price, ok := row["price"].(float64)
+			if !ok {
+				return nil
+			}
+			qty, _ := convertToFloat(row["qty"])
+			return qty * price
+		})),
+	})
+	id, err := db.InsertReturningID("orders", map[string]any{"qty": 3, "price": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.GetByPrimaryKey("orders", id); row["total"] != 4.5 {
+		t.Fatalf("total = %v, want 4.5", row["total"])
+	}
+	if _, err := db.UpdateRecords("orders", nil, map[string]any{"qty": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.GetByPrimaryKey("orders", id); row["total"] != 6.0 {
+		t.Fatalf("total after an update = %v, want 6", row["total"])
+	}
+	if _, err := db.UpdateRecords("orders", nil, map[string]any{"price": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.GetByPrimaryKey("orders", id); row["total"] != nil {
+		t.Fatalf("total without a price = %v, want NULL", row["total"])
+	}
+
+	if err := db.InsertRecord("orders", map[string]any{"qty": 1, "total": 9.0}); err == nil {
+		t.Fatal("setting a computed column on insert succeeded")
+	}
+	if _, err := db.UpdateRecords("orders", nil, map[string]any{"total": 9.0}); err == nil {
+		t.Fatal("setting a computed column on update succeeded")
+	}
+}
+
+func TestGeneratedColumnSchemaErrors(t *testing.T) {
+	for name, col := range map[string]*Column{
+		"auto-increment string": NewColumn("id", TypeString, AutoIncrement()),
+		"default of wrong type": NewColumn("n", TypeInt, Default("many")),
+		"computed with default": NewColumn("n", TypeInt, Default(1), Computed(func(map[string]any) any { return 2 })),
+	} {
+		if err := NewDatabase().CreateTable("t", []*Column{col}); err == nil {
+			t.Errorf("%s: table was created", name)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package sqldb
+
+import "testing"
+
+// createTable adds a table to db and inserts rows into it, one InsertRecord each.
+func createTable(t *testing.T, db *Database, name string, columns []*Column, rows ...map[string]any) *Table {
+	t.Helper()
+	if err := db.CreateTable(name, columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := db.InsertRecord(name, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	table, err := db.GetTable(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+// usersColumns is the users schema most tests start from.
+func usersColumns() []*Column {
+	return []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("name", TypeString, Required(), MaxLength(5)),
+	}
+}
+
+// playerColumns and playerRows make up the players table the query and
+// aggregate tests read; dee has no score.
+func playerColumns() []*Column {
+	return []*Column{
+		NewColumn("name", TypeString),
+		NewColumn("team", TypeString),
+		NewColumn("score", TypeInt),
+	}
+}
+
+func playerRows() []map[string]any {
+	return []map[string]any{
+		{"name": "ada", "team": "red", "score": 3},
+		{"name": "bob", "team": "blue", "score": 5},
+		{"name": "cy", "team": "red", "score": 5},
+		{"name": "dee", "team": "blue"},
+		{"name": "eve", "team": "red", "score": 1},
+	}
+}
+
+func mustExec(t *testing.T, db *Database, query string, args ...any) Result {
+	t.Helper()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return res
+}
+
+func mustQuery(t *testing.T, db *Database, query string, args ...any) *ResultSet {
+	t.Helper()
+	rs, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return rs
+}
+
+func rowCount(t *testing.T, db *Database, table string) int {
+	t.Helper()
+	rows, err := db.GetRecords(table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(rows)
+}
+
+// columnValues lists the values rows hold for column, in order.
+func columnValues(rows []map[string]any, column string) []any {
+	values := make([]any, len(rows))
+	for i, row := range rows {
+		values[i] = row[column]
+	}
+	return values
+}
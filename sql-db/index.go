@@ -0,0 +1,279 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type IndexKind string
+
+const (
+	HashIndex    IndexKind = "hash"    // equality and IN lookups
+	OrderedIndex IndexKind = "ordered" // equality, IN and range lookups
+)
+
+// Index maps column values to the row versions holding them. Versions stay in the
+// index until vacuum drops them, so lookups still have to check visibility.
+// NULL values are not indexed.
+type Index struct {
+	Column  string
+	Kind    IndexKind
+	col     *Column
+	hash    map[any][]*rowVersion
+	ordered *skipList
+}
+
+func newIndex(col *Column, kind IndexKind) *Index {
+	idx := &Index{Column: col.Name, Kind: kind, col: col}
+	if kind == OrderedIndex {
+		idx.ordered = newSkipList()
+	} else {
+		idx.hash = make(map[any][]*rowVersion)
+	}
+	return idx
+}
+
+func (idx *Index) insert(v *rowVersion) {
+	val := v.data[idx.Column]
+	if val == nil {
+		return
+	}
+	key := idx.key(val)
+	if idx.ordered != nil {
+		idx.ordered.insert(key, v)
+		return
+	}
+	idx.hash[key] = append(idx.hash[key], v)
+}
+
+func (idx *Index) lookup(val any) []*rowVersion {
+	if !idx.accepts(val) {
+		return nil
+	}
+	key := idx.key(val)
+	if idx.ordered != nil {
+		return idx.ordered.lookup(key)
+	}
+	return idx.hash[key]
+}
+
+// scanRange returns versions for keys between lo and hi; a nil bound is unbounded.
+func (idx *Index) scanRange(lo any, loInclusive bool, hi any, hiInclusive bool) []*rowVersion {
+	if lo != nil {
+		lo = idx.key(lo)
+	}
+	if hi != nil {
+		hi = idx.key(hi)
+	}
+	return idx.ordered.scanRange(lo, loInclusive, hi, hiInclusive)
+}
+
+// accepts reports whether val can be compared with the indexed column's values.
+// Values of any other type can never match, so lookups for them are empty.
+func (idx *Index) accepts(val any) bool {
+	if val == nil {
+		return false
+	}
+	_, err := idx.col.convert(val)
+	return err == nil
+}
+
+// key converts an accepted value to a comparable map key, so that equal values
+// of different Go types (int and int64, string and time.Time) share a key.
+func (idx *Index) key(val any) any {
+	converted, _ := idx.col.convert(val)
+	return normalizeKey(converted)
+}
+
+// normalizeKey maps a stored value to a comparable value that is equal for equal
+// stored values, e.g. the same instant in different time zones.
+func normalizeKey(converted any) any {
+	switch v := converted.(type) {
+	case time.Time:
+		return v.UnixNano()
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	}
+	if n, err := convertToInt(converted); err == nil {
+		return n
+	}
+	return converted
+}
+
+// CreateIndex builds an index over column and keeps it in sync on every write.
+func (t *Table) CreateIndex(column string, kind IndexKind) error {
+	col := t.GetColumn(column)
+	if col == nil {
+		return unknownColumn(column)
+	}
+	if kind != HashIndex && kind != OrderedIndex {
+		return fmt.Errorf("unknown index kind %s", kind)
+	}
+
+	// holding the write lock keeps commits from adding versions while the index is built
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.index(column) != nil {
+		return fmt.Errorf("index on %s.%s already exists", t.Name, column)
+	}
+	idx := newIndex(col, kind)
+	for _, v := range *t.versions.Load() {
+		idx.insert(v)
+	}
+
+	t.idxMu.Lock()
+	defer t.idxMu.Unlock()
+	t.indexes[column] = idx
+	return nil
+}
+
+func (t *Table) DropIndex(column string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.idxMu.Lock()
+	defer t.idxMu.Unlock()
+
+	if _, exists := t.indexes[column]; !exists {
+		return fmt.Errorf("no index on %s.%s", t.Name, column)
+	}
+	if t.GetColumn(column).Constraints.Unique {
+		return fmt.Errorf("index on %s.%s backs a unique constraint", t.Name, column)
+	}
+	if t.GetColumn(column).Constraints.References != nil {
+		return fmt.Errorf("index on %s.%s backs a foreign key", t.Name, column)
+	}
+	delete(t.indexes, column)
+	return nil
+}
+
+func (t *Table) Indexes() []*Index {
+	t.idxMu.RLock()
+	defer t.idxMu.RUnlock()
+
+	indexes := make([]*Index, 0, len(t.indexes))
+	for _, idx := range t.indexes {
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Column < indexes[j].Column })
+	return indexes
+}
+
+func (t *Table) index(column string) *Index {
+	t.idxMu.RLock()
+	defer t.idxMu.RUnlock()
+	return t.indexes[column]
+}
+
+// lookupIndex returns a copy of the versions idx holds for val.
+func (t *Table) lookupIndex(idx *Index, val any) []*rowVersion {
+	t.idxMu.RLock()
+	defer t.idxMu.RUnlock()
+	return append([]*rowVersion(nil), idx.lookup(val)...)
+}
+
+// uniqueVersions drops repeated versions, which unions of index lookups can produce.
+func uniqueVersions(versions []*rowVersion) []*rowVersion {
+	seen := make(map[*rowVersion]bool, len(versions))
+	unique := versions[:0]
+	for _, v := range versions {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+const skipListMaxLevel = 24
+
+type skipNode struct {
+	key      any
+	versions []*rowVersion
+	next     []*skipNode
+}
+
+// skipList is an ordered map from index keys to row versions.
+type skipList struct {
+	head  *skipNode
+	level int
+}
+
+func newSkipList() *skipList {
+	return &skipList{head: &skipNode{next: make([]*skipNode, skipListMaxLevel)}, level: 1}
+}
+
+func (sl *skipList) less(a, b any) bool {
+	cmp, _ := compareValues(a, b)
+	return cmp < 0
+}
+
+// seek fills update with the last node before key on every level and returns
+// the first node whose key is >= key.
+func (sl *skipList) seek(key any, update []*skipNode) *skipNode {
+	node := sl.head
+	for lvl := sl.level - 1; lvl >= 0; lvl-- {
+		for node.next[lvl] != nil && sl.less(node.next[lvl].key, key) {
+			node = node.next[lvl]
+		}
+		if update != nil {
+			update[lvl] = node
+		}
+	}
+	return node.next[0]
+}
+
+func (sl *skipList) insert(key any, v *rowVersion) {
+	update := make([]*skipNode, skipListMaxLevel)
+	node := sl.seek(key, update)
+	if node != nil && !sl.less(key, node.key) {
+		node.versions = append(node.versions, v)
+		return
+	}
+
+	lvl := 1
+	for lvl < skipListMaxLevel && rand.Intn(4) == 0 {
+		lvl++
+	}
+	if lvl > sl.level {
+		for i := sl.level; i < lvl; i++ {
+			update[i] = sl.head
+		}
+		sl.level = lvl
+	}
+	newNode := &skipNode{key: key, versions: []*rowVersion{v}, next: make([]*skipNode, lvl)}
+	for i := 0; i < lvl; i++ {
+		newNode.next[i] = update[i].next[i]
+		update[i].next[i] = newNode
+	}
+}
+
+func (sl *skipList) lookup(key any) []*rowVersion {
+	node := sl.seek(key, nil)
+	if node == nil || sl.less(key, node.key) {
+		return nil
+	}
+	return node.versions
+}
+
+func (sl *skipList) scanRange(lo any, loInclusive bool, hi any, hiInclusive bool) []*rowVersion {
+	node := sl.head.next[0]
+	if lo != nil {
+		node = sl.seek(lo, nil)
+		if node != nil && !loInclusive && !sl.less(lo, node.key) {
+			node = node.next[0]
+		}
+	}
+	var versions []*rowVersion
+	for ; node != nil; node = node.next[0] {
+		if hi != nil && (sl.less(hi, node.key) || (!hiInclusive && !sl.less(node.key, hi))) {
+			break
+		}
+		versions = append(versions, node.versions...)
+	}
+	return versions
+}
@@ -0,0 +1,109 @@
+package sqldb
+
+import (
+	"fmt"
+	"testing"
+)
+
+// indexedRows reads the rows matching pred, failing unless an index found them.
+func indexedRows(t *testing.T, table *Table, pred Predicate) []map[string]any {
+	t.Helper()
+	var refs []rowRef
+	var path *accessPath
+	table.read(func(snapshot uint64) {
+		refs, path = table.scanPath(snapshot, pred, nil)
+	})
+	if path == nil {
+		t.Fatalf("%v was read by a full scan", pred)
+	}
+	return refsToRows(refs)
+}
+
+func TestIndexLookups(t *testing.T) {
+	rows := make([]map[string]any, 1000)
+	for i := range rows {
+		rows[i] = map[string]any{"name": fmt.Sprintf("user%d", i), "score": i}
+	}
+	table := createTable(t, NewDatabase(), "scores", []*Column{
+		NewColumn("name", TypeString),
+		NewColumn("score", TypeInt),
+	}, rows...)
+	if err := table.CreateIndex("name", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.CreateIndex("score", OrderedIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	if rows := indexedRows(t, table, Eq("name", "user42")); len(rows) != 1 || rows[0]["score"] != int64(42) {
+		t.Fatalf("hash lookup = %v, want user42", rows)
+	}
+	if rows := indexedRows(t, table, Eq("score", int32(7))); len(rows) != 1 || rows[0]["name"] != "user7" {
+		t.Fatalf("ordered lookup = %v, want user7", rows)
+	}
+	rows = indexedRows(t, table, And(Ge("score", 10), Lt("score", 15)))
+	if len(rows) != 5 {
+		t.Fatalf("range lookup found %d rows, want 5", len(rows))
+	}
+	for i, row := range rows {
+		if row["score"] != int64(10+i) {
+			t.Fatalf("range lookup row %d = %v", i, row)
+		}
+	}
+	if rows := indexedRows(t, table, In("name", "user1", "user2", "nobody")); len(rows) != 2 {
+		t.Fatalf("IN lookup found %d rows, want 2", len(rows))
+	}
+	if rows := indexedRows(t, table, Eq("score", "not a number")); len(rows) != 0 {
+		t.Fatalf("lookup of a value of the wrong type = %v", rows)
+	}
+
+	// the indexes follow updates, deletes and inserts
+	if _, err := table.UpdateRows(map[string]any{"name": "user5"}, map[string]any{"name": "renamed", "score": 5000}); err != nil {
+		t.Fatal(err)
+	}
+	if rows := indexedRows(t, table, Eq("name", "user5")); len(rows) != 0 {
+		t.Fatalf("old key still finds %v", rows)
+	}
+	if rows := indexedRows(t, table, Eq("name", "renamed")); len(rows) != 1 {
+		t.Fatalf("new key finds %d rows, want 1", len(rows))
+	}
+	if rows := indexedRows(t, table, Gt("score", 999)); len(rows) != 1 || rows[0]["name"] != "renamed" {
+		t.Fatalf("range over the new score = %v", rows)
+	}
+
+	if _, err := table.DeleteRows(map[string]any{"name": "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.AddRow(map[string]any{"name": "late", "score": 12}); err != nil {
+		t.Fatal(err)
+	}
+	table.Vacuum()
+	if rows := indexedRows(t, table, Eq("name", "renamed")); len(rows) != 0 {
+		t.Fatalf("deleted row still found: %v", rows)
+	}
+	if rows := indexedRows(t, table, Eq("score", 12)); len(rows) != 2 {
+		t.Fatalf("score 12 finds %d rows, want 2", len(rows))
+	}
+}
+
+func TestCreateIndexErrors(t *testing.T) {
+	table := createTable(t, NewDatabase(), "scores", []*Column{NewColumn("name", TypeString)})
+	if err := table.CreateIndex("name", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.CreateIndex("name", OrderedIndex); err == nil {
+		t.Fatal("second index on a column succeeded")
+	}
+	if err := table.CreateIndex("nope", HashIndex); err == nil {
+		t.Fatal("index on an unknown column succeeded")
+	}
+	if err := table.DropIndex("name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.DropIndex("name"); err == nil {
+		t.Fatal("dropping a missing index succeeded")
+	}
+	if err := table.CreateIndex("name", "btree"); err == nil {
+		t.Fatal("index of an unknown kind succeeded")
+	}
+}
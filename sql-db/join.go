@@ -0,0 +1,445 @@
+package sqldb
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type JoinKind string
+
+const (
+	InnerJoin JoinKind = "INNER" // rows without a match are dropped
+	LeftJoin  JoinKind = "LEFT"  // rows without a match are kept, with NULL for the joined columns
+)
+
+// Join combines each row read so far with the rows of Table where column On equals
+// column To. One of them is a column of Table and the other a column of a table read
+// before, both qualified with their table name.
+type Join struct {
+	Kind  JoinKind
+	Table string
+	On    string
+	To    string
+}
+
+// source is a table a query reads, with the uncommitted changes of the reading
+// transaction, if any.
+type source struct {
+	table *Table
+	ws    *writeSet
+}
+
+// resolveJoins looks up the tables of joins.
+func resolveJoins(joins []Join, lookup func(name string) (source, error)) ([]source, error) {
+	sources := make([]source, 0, len(joins))
+	for _, join := range joins {
+		src, err := lookup(join.Table)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// joinColumns returns the columns of a join, named table.column, and checks the joins.
+func joinColumns(from source, joined []source, joins []Join) ([]*Column, error) {
+	columns := qualify(nil, from.table)
+	seen := map[string]bool{from.table.Name: true}
+	for i, join := range joins {
+		switch join.Kind {
+		case InnerJoin, LeftJoin:
+		default:
+			return nil, fmt.Errorf("unknown join kind %s", join.Kind)
+		}
+		t := joined[i].table
+		if seen[t.Name] {
+			return nil, fmt.Errorf("table %s is joined more than once", t.Name)
+		}
+		seen[t.Name] = true
+		if _, _, err := joinKeys(join, columns, t); err != nil {
+			return nil, err
+		}
+		columns = qualify(columns, t)
+	}
+	return columns, nil
+}
+
+// joinKeys splits the condition of join into the column of the rows read so far and
+// the column of the joined table.
+func joinKeys(join Join, before []*Column, t *Table) (left string, right string, err error) {
+	prefix := t.Name + "."
+	left, right = join.On, join.To
+	if strings.HasPrefix(left, prefix) {
+		left, right = right, left
+	}
+	if findColumn(before, left) == nil {
+		return "", "", fmt.Errorf("join with %s: %w", t.Name, unknownColumn(left))
+	}
+	name, ok := strings.CutPrefix(right, prefix)
+	if !ok || t.GetColumn(name) == nil {
+		return "", "", fmt.Errorf("join with %s: %w", t.Name, unknownColumn(right))
+	}
+	return left, name, nil
+}
+
+// joinPlan is the order in which the tables of a join are read and combined.
+type joinPlan struct {
+	steps     []*joinStep
+	where     Predicate // WHERE conjuncts left for the joined rows
+	reordered bool      // steps are not in the order the query lists the tables
+	e         estimator // stats of every column, by qualified name
+}
+
+// joinStep reads one table and hash joins its rows with those of the steps before.
+type joinStep struct {
+	src    source
+	pos    int         // 0 for the FROM table, i+1 for Joins[i]
+	kind   JoinKind    // how the step joins, InnerJoin for the first one
+	on     [][2]string // qualified column of an earlier step = column of src
+	filter Predicate   // WHERE conjuncts pushed down to the scan, with plain column names
+	rows   float64     // estimated rows of src passing filter
+	out    float64     // estimated rows after the step
+	cost   float64     // estimated cost up to and including the step
+}
+
+// joinEdge is a join condition: the column a of one table equals b of another, both
+// qualified.
+type joinEdge struct {
+	a, b string
+}
+
+// planJoins pushes every WHERE conjunct that only reads one table down to that
+// table's scan, where it can use an index, and orders inner joins so that the
+// estimated intermediate results stay small. Tables are kept in query order when
+// there are left joins.
+func planJoins(from source, joined []source, opts QueryOptions) *joinPlan {
+	plan := &joinPlan{e: queryEstimator(from, joined)}
+	steps := []*joinStep{{src: from, kind: InnerJoin}}
+	byName := map[string]*joinStep{from.table.Name: steps[0]}
+	var edges []joinEdge
+	before := qualify(nil, from.table)
+	leftJoins := false
+	for i, join := range opts.Joins {
+		t := joined[i].table
+		step := &joinStep{src: joined[i], pos: i + 1, kind: join.Kind}
+		steps = append(steps, step)
+		byName[t.Name] = step
+		left, right, _ := joinKeys(join, before, t)
+		edges = append(edges, joinEdge{a: left, b: t.Name + "." + right})
+		before = qualify(before, t)
+		leftJoins = leftJoins || join.Kind == LeftJoin
+	}
+
+	var conjuncts []Predicate
+	if and, ok := opts.Where.(AndPredicate); ok {
+		conjuncts = and
+	} else if opts.Where != nil {
+		conjuncts = []Predicate{opts.Where}
+	}
+	var rest AndPredicate
+	for _, conjunct := range conjuncts {
+		// filtering the rows a left join adds NULLs for has to wait for the join
+		if step := singleTable(conjunct, byName); step != nil && step.kind != LeftJoin {
+			prefix := step.src.table.Name + "."
+			if pushed, ok := renameColumns(conjunct, func(col string) string { return strings.TrimPrefix(col, prefix) }); ok {
+				step.filter = And(step.filter, pushed)
+				continue
+			}
+		}
+		rest = append(rest, conjunct)
+	}
+	if len(rest) > 0 {
+		plan.where = rest
+	}
+	for _, step := range steps {
+		step.filter = flattenAnd(step.filter)
+		e := make(estimator)
+		e.addTable(step.src.table, "")
+		step.rows = float64(step.src.table.statistics().rows) * e.selectivity(step.filter)
+	}
+
+	if leftJoins {
+		plan.steps = steps
+	} else {
+		plan.steps = orderJoins(steps, edges, plan.e)
+	}
+	placed := make(map[string]bool)
+	for i, step := range plan.steps {
+		name := step.src.table.Name
+		plan.reordered = plan.reordered || step.pos != i
+		for _, edge := range edges {
+			a, b := edge.a, edge.b
+			if tableOf(a) == name {
+				a, b = b, a
+			}
+			if tableOf(b) == name && placed[tableOf(a)] {
+				step.on = append(step.on, [2]string{a, strings.TrimPrefix(b, name+".")})
+			}
+		}
+		placed[name] = true
+		plan.estimate(i)
+	}
+	return plan
+}
+
+// orderJoins orders inner joins greedily: the table with the fewest estimated rows
+// first, then always the connected table giving the smallest estimated result.
+func orderJoins(steps []*joinStep, edges []joinEdge, e estimator) []*joinStep {
+	remaining := append([]*joinStep(nil), steps...)
+	first := 0
+	for i, step := range remaining {
+		if step.rows < remaining[first].rows {
+			first = i
+		}
+	}
+	ordered := []*joinStep{remaining[first]}
+	placed := map[string]bool{remaining[first].src.table.Name: true}
+	rows := remaining[first].rows
+	remaining = append(remaining[:first], remaining[first+1:]...)
+
+	for len(remaining) > 0 {
+		best, bestRows := -1, 0.0
+		for i, step := range remaining {
+			name := step.src.table.Name
+			for _, edge := range edges {
+				var own, other string
+				switch {
+				case tableOf(edge.a) == name && placed[tableOf(edge.b)]:
+					own, other = edge.a, edge.b
+				case tableOf(edge.b) == name && placed[tableOf(edge.a)]:
+					own, other = edge.b, edge.a
+				default:
+					continue
+				}
+				out := joinRowsEstimate(rows, step.rows, e, other, own)
+				if best < 0 || out < bestRows {
+					best, bestRows = i, out
+				}
+			}
+		}
+		step := remaining[best]
+		ordered = append(ordered, step)
+		placed[step.src.table.Name] = true
+		rows = bestRows
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return ordered
+}
+
+// joinRowsEstimate estimates the rows of an equi-join on a = b, assuming the side
+// with fewer distinct values only holds values the other side has.
+func joinRowsEstimate(left, right float64, e estimator, a, b string) float64 {
+	distinct := max(e[a].distinct, e[b].distinct, 1)
+	return left * right / float64(distinct)
+}
+
+// estimate fills in the estimated rows and cost after step i.
+func (plan *joinPlan) estimate(i int) {
+	step := plan.steps[i]
+	scanCost := float64(step.src.table.statistics().versions) * seqRowCost
+	if i == 0 {
+		step.out, step.cost = step.rows, scanCost
+		return
+	}
+	prev := plan.steps[i-1]
+	step.out = prev.out * step.rows
+	if len(step.on) > 0 {
+		step.out = joinRowsEstimate(prev.out, step.rows, plan.e, step.on[0][0], step.src.table.Name+"."+step.on[0][1])
+	}
+	if step.kind == LeftJoin {
+		step.out = max(step.out, prev.out)
+	}
+	step.cost = prev.cost + scanCost + (prev.out+step.rows)*hashRowCost
+}
+
+// singleTable returns the step of the only table pred reads, or nil.
+func singleTable(pred Predicate, byName map[string]*joinStep) *joinStep {
+	var step *joinStep
+	for _, col := range predicateColumns(pred) {
+		s := byName[tableOf(col)]
+		if s == nil || (step != nil && s != step) {
+			return nil
+		}
+		step = s
+	}
+	return step
+}
+
+func tableOf(qualified string) string {
+	table, _, _ := strings.Cut(qualified, ".")
+	return table
+}
+
+// flattenAnd drops the nesting And(And(a, b), c) builds up, and nil conjuncts.
+func flattenAnd(pred Predicate) Predicate {
+	and, ok := pred.(AndPredicate)
+	if !ok {
+		return pred
+	}
+	var flat AndPredicate
+	for _, child := range and {
+		switch c := flattenAnd(child).(type) {
+		case nil:
+		case AndPredicate:
+			flat = append(flat, c...)
+		default:
+			flat = append(flat, c)
+		}
+	}
+	switch len(flat) {
+	case 0:
+		return nil
+	case 1:
+		return flat[0]
+	}
+	return flat
+}
+
+// joinedRow is a row of a join with the ids of the rows it combines, by position of
+// their table in the query; -1 stands for the NULLs of a left join.
+type joinedRow struct {
+	data map[string]any
+	ids  []int64
+}
+
+// run reads and joins the tables, then filters the combined rows by what is left of
+// WHERE. The returned node describes the run when explain is set.
+func (plan *joinPlan) run(snapshot uint64, explain bool) ([]rowRef, *PlanNode) {
+	var rows []joinedRow
+	var node *PlanNode
+	for i, step := range plan.steps {
+		t := step.src.table
+		refs, path := t.scanPath(snapshot, step.filter, step.src.ws)
+		var scanNode *PlanNode
+		if explain {
+			scanNode = t.scanNode(step.filter, path, len(refs))
+		}
+		if i == 0 {
+			for _, ref := range refs {
+				ids := make([]int64, len(plan.steps))
+				ids[step.pos] = ref.id
+				rows = append(rows, joinedRow{data: prefixRow(make(map[string]any), t.Name, ref.data), ids: ids})
+			}
+			node = scanNode
+			continue
+		}
+
+		rows = step.join(rows, refs)
+		if explain {
+			var conds []string
+			for _, on := range step.on {
+				conds = append(conds, fmt.Sprintf("%s = %s.%s", on[0], t.Name, on[1]))
+			}
+			op := "Hash Join"
+			if step.kind == LeftJoin {
+				op = "Hash Left Join"
+			}
+			node = &PlanNode{
+				Op:        op,
+				Detail:    "on " + strings.Join(conds, " AND "),
+				Cost:      step.cost,
+				Estimated: step.out,
+				Actual:    len(rows),
+				Children:  []*PlanNode{node, scanNode},
+			}
+		}
+	}
+
+	if plan.reordered {
+		// keep the order a join in query order would produce
+		sort.Slice(rows, func(i, j int) bool { return slices.Compare(rows[i].ids, rows[j].ids) < 0 })
+	}
+	var refs []rowRef
+	for i, row := range rows {
+		if rowMatches(row.data, plan.where) {
+			refs = append(refs, rowRef{id: int64(i), data: row.data})
+		}
+	}
+	if explain && plan.where != nil {
+		last := plan.steps[len(plan.steps)-1]
+		node = &PlanNode{
+			Op:        "Filter",
+			Detail:    fmt.Sprint(plan.where),
+			Cost:      last.cost + last.out*seqRowCost,
+			Estimated: last.out * plan.e.selectivity(plan.where),
+			Actual:    len(refs),
+			Children:  []*PlanNode{node},
+		}
+	}
+	return refs, node
+}
+
+// join hash joins rows with the rows of the step's table.
+func (step *joinStep) join(rows []joinedRow, refs []rowRef) []joinedRow {
+	t := step.src.table
+	key := step.on[0]
+	matches := make(map[any][]rowRef)
+	for _, ref := range refs {
+		if val := ref.data[key[1]]; val != nil {
+			k := normalizeKey(val)
+			matches[k] = append(matches[k], ref)
+		}
+	}
+
+	var combined []joinedRow
+	for _, row := range rows {
+		found := 0
+		if val := row.data[key[0]]; val != nil {
+			for _, ref := range matches[normalizeKey(val)] {
+				if !step.matchesRest(row.data, ref.data) {
+					continue
+				}
+				ids := slices.Clone(row.ids)
+				ids[step.pos] = ref.id
+				combined = append(combined, joinedRow{data: prefixRow(copyMap(row.data), t.Name, ref.data), ids: ids})
+				found++
+			}
+		}
+		if found == 0 && step.kind == LeftJoin {
+			ids := slices.Clone(row.ids)
+			ids[step.pos] = -1
+			combined = append(combined, joinedRow{data: row.data, ids: ids})
+		}
+	}
+	return combined
+}
+
+// matchesRest checks the join conditions after the first, which the hash table
+// doesn't cover.
+func (step *joinStep) matchesRest(row, data map[string]any) bool {
+	for _, on := range step.on[1:] {
+		a, b := row[on[0]], data[on[1]]
+		if a == nil || b == nil || !sameKey(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// qualify appends copies of the columns of t named table.column.
+func qualify(columns []*Column, t *Table) []*Column {
+	for _, col := range t.Columns {
+		qualified := *col
+		qualified.Name = t.Name + "." + col.Name
+		columns = append(columns, &qualified)
+	}
+	return columns
+}
+
+func prefixRow(dst map[string]any, table string, data map[string]any) map[string]any {
+	for col, val := range data {
+		dst[table+"."+col] = val
+	}
+	return dst
+}
+
+func copyMap(row map[string]any) map[string]any {
+	copied := make(map[string]any, len(row))
+	for col, val := range row {
+		copied[col] = val
+	}
+	return copied
+}
@@ -0,0 +1,126 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// shopSQL creates users, orders referencing them with the ON DELETE action
+// it is formatted with, and order notes referencing orders with CASCADE.
+const shopSQL = `
+	CREATE TABLE users (id INT PRIMARY KEY, name STRING NOT NULL);
+	CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES users ON DELETE %s, item STRING);
+	CREATE TABLE notes (id INT PRIMARY KEY, order_id INT NOT NULL REFERENCES orders ON DELETE CASCADE);
+	INSERT INTO users (id, name) VALUES (1, 'ada'), (2, 'bob');
+	INSERT INTO orders (id, user_id, item) VALUES (10, 1, 'pen'), (11, 1, 'ink');
+	INSERT INTO notes (id, order_id) VALUES (100, 10);
+`
+
+func TestForeignKeyRejectsMissingParent(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "RESTRICT"))
+	err := db.InsertRecord("orders", map[string]any{"id": 12, "user_id": 3})
+	var violation *ConstraintViolation
+	if !errors.As(err, &violation) || violation.Constraint != ConstraintForeignKey {
+		t.Fatalf("got %v, want a foreign key violation", err)
+	}
+	if err := db.InsertRecord("orders", map[string]any{"id": 12}); err != nil {
+		t.Fatalf("order without a user: %v", err)
+	}
+	if _, err := db.UpdateRecords("orders", map[string]any{"id": 10}, map[string]any{"user_id": 3}); err == nil {
+		t.Fatal("pointing an order at a missing user succeeded")
+	}
+	if _, err := db.UpdateRecords("users", map[string]any{"id": 1}, map[string]any{"id": 5}); err == nil {
+		t.Fatal("changing a referenced primary key succeeded")
+	}
+}
+
+func TestForeignKeyOnDelete(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "RESTRICT"))
+	if _, err := db.DeleteRecords("users", map[string]any{"id": 1}); err == nil {
+		t.Fatal("deleting a referenced user succeeded")
+	}
+	if _, err := db.DeleteRecords("users", map[string]any{"id": 2}); err != nil {
+		t.Fatalf("deleting an unreferenced user: %v", err)
+	}
+
+	db = NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "CASCADE"))
+	if _, err := db.DeleteRecords("users", map[string]any{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	// the cascade goes on from orders to their notes
+	if n, m := rowCount(t, db, "orders"), rowCount(t, db, "notes"); n != 0 || m != 0 {
+		t.Fatalf("%d orders and %d notes left after the cascade, want none", n, m)
+	}
+
+	db = NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "SET NULL"))
+	if _, err := db.DeleteRecords("users", map[string]any{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := db.GetRecordsWhere("orders", Null("user_id"))
+	if len(rows) != 2 {
+		t.Fatalf("%d orders lost their user, want 2", len(rows))
+	}
+}
+
+func TestForeignKeyCheckedAtCommit(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "RESTRICT"))
+	tx := db.Begin()
+	if err := tx.InsertRecord("orders", map[string]any{"id": 12, "user_id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	// the user goes away after the transaction's snapshot
+	if _, err := db.DeleteRecords("users", map[string]any{"id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("committing an order for a deleted user succeeded")
+	}
+}
+
+func TestJoins(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, fmt.Sprintf(shopSQL, "RESTRICT"))
+	rs, err := db.Select("users", QueryOptions{
+		Columns: []string{"users.name", "orders.item"},
+		Joins:   []Join{{Kind: LeftJoin, Table: "orders", On: "orders.user_id", To: "users.id"}},
+		OrderBy: []OrderBy{Asc("users.id"), Asc("orders.id")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"users.name": "ada", "orders.item": "pen"},
+		{"users.name": "ada", "orders.item": "ink"},
+		{"users.name": "bob"},
+	}
+	if !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("left join = %v, want %v", rs.Rows, want)
+	}
+
+	rs, err = db.Select("notes", QueryOptions{
+		Columns: []string{"notes.id", "users.name"},
+		Joins: []Join{
+			{Kind: InnerJoin, Table: "orders", On: "orders.id", To: "notes.order_id"},
+			{Kind: InnerJoin, Table: "users", On: "users.id", To: "orders.user_id"},
+		},
+		Where: Eq("orders.item", "pen"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[string]any{{"notes.id": int64(100), "users.name": "ada"}}; !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("inner joins = %v, want %v", rs.Rows, want)
+	}
+
+	_, err = db.Select("users", QueryOptions{Joins: []Join{{Kind: InnerJoin, Table: "orders", On: "orders.user_id", To: "id"}}})
+	if err == nil {
+		t.Fatal("join on an unqualified column succeeded")
+	}
+}
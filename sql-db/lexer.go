@@ -0,0 +1,196 @@
+package sqldb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokNumber
+	tokString
+	tokBytes // x'..' hex literal, text holds the decoded bytes
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string // keywords are upper-cased, identifiers keep their case
+	pos  int
+}
+
+var keywords = map[string]bool{
+	"CREATE": true, "TABLE": true, "DROP": true, "INSERT": true, "INTO": true,
+	"VALUES": true, "SELECT": true, "FROM": true, "WHERE": true, "UPDATE": true,
+	"SET": true, "DELETE": true, "AND": true, "NOT": true, "NULL": true,
+	"CHECK": true, "OR": true, "IN": true, "LIKE": true, "IS": true,
+	"INDEX": true, "ON": true, "USING": true, "PRIMARY": true, "KEY": true,
+	"UNIQUE": true, "TRUE": true, "FALSE": true, "ALTER": true, "ADD": true,
+	"COLUMN": true, "RENAME": true, "TO": true, "MODIFY": true, "DEFAULT": true,
+	"ORDER": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true,
+	"GROUP": true, "HAVING": true, "AS": true, "JOIN": true, "INNER": true, "LEFT": true,
+	"OUTER": true, "REFERENCES": true, "CASCADE": true, "RESTRICT": true, "EXPLAIN": true,
+	"AUTO_INCREMENT": true, "AUTOINCREMENT": true,
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// tokenize splits a query into tokens, always ending with a tokEOF token.
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src}
+	var tokens []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpaceAndComments()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+	start := l.pos
+	ch := rune(l.src[l.pos])
+
+	switch {
+	case (ch == 'x' || ch == 'X') && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'':
+		l.pos++
+		tok, err := l.readString()
+		if err != nil {
+			return token{}, err
+		}
+		decoded, err := hex.DecodeString(tok.text)
+		if err != nil {
+			return token{}, fmt.Errorf("invalid hex literal at position %d", start)
+		}
+		return token{kind: tokBytes, text: string(decoded), pos: start}, nil
+
+	case ch == '_' || unicode.IsLetter(ch):
+		for l.pos < len(l.src) && isIdentChar(rune(l.src[l.pos])) {
+			l.pos++
+			// a qualified column name such as users.id is a single identifier
+			if l.pos+1 < len(l.src) && l.src[l.pos] == '.' && isIdentStart(rune(l.src[l.pos+1])) {
+				l.pos++
+			}
+		}
+		word := l.src[start:l.pos]
+		if upper := strings.ToUpper(word); keywords[upper] {
+			return token{kind: tokKeyword, text: upper, pos: start}, nil
+		}
+		return token{kind: tokIdent, text: word, pos: start}, nil
+
+	case unicode.IsDigit(ch):
+		l.skipDigits()
+		if l.pos+1 < len(l.src) && l.src[l.pos] == '.' && isDigit(l.src[l.pos+1]) {
+			l.pos++
+			l.skipDigits()
+		}
+		if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+			exp := l.pos + 1
+			if exp < len(l.src) && (l.src[exp] == '+' || l.src[exp] == '-') {
+				exp++
+			}
+			if exp < len(l.src) && isDigit(l.src[exp]) {
+				l.pos = exp
+				l.skipDigits()
+			}
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+
+	case ch == '\'':
+		return l.readString()
+
+	case ch == '"':
+		// quoted identifier
+		l.pos++
+		end := strings.IndexByte(l.src[l.pos:], '"')
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated quoted identifier at position %d", start)
+		}
+		l.pos += end + 1
+		return token{kind: tokIdent, text: l.src[start+1 : l.pos-1], pos: start}, nil
+	}
+
+	for _, sym := range []string{"<=", ">=", "!=", "<>"} {
+		if strings.HasPrefix(l.src[l.pos:], sym) {
+			l.pos += len(sym)
+			return token{kind: tokSymbol, text: sym, pos: start}, nil
+		}
+	}
+	if strings.ContainsRune("(),;*=<>-~?", ch) {
+		l.pos++
+		return token{kind: tokSymbol, text: string(ch), pos: start}, nil
+	}
+	return token{}, fmt.Errorf("unexpected character %q at position %d", ch, start)
+}
+
+func (l *lexer) readString() (token, error) {
+	start := l.pos
+	l.pos++ // opening quote
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		if ch == '\'' {
+			// '' is an escaped quote
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '\'' {
+				sb.WriteByte('\'')
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			return token{kind: tokString, text: sb.String(), pos: start}, nil
+		}
+		sb.WriteByte(ch)
+		l.pos++
+	}
+	return token{}, fmt.Errorf("unterminated string literal at position %d", start)
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.src) {
+		switch {
+		case unicode.IsSpace(rune(l.src[l.pos])):
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) skipDigits() {
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
+func isIdentChar(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
@@ -0,0 +1,199 @@
+package lldsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+// conn runs statements against the database, or against its open transaction.
+type conn struct {
+	db *sqldb.Database
+	tx *sqldb.Tx
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if c.tx != nil {
+		c.tx.Rollback()
+		c.tx = nil
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a snapshot isolation transaction, which is at least as strong as
+// every level up to repeatable read.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSnapshot:
+	default:
+		return nil, fmt.Errorf("lldsql: isolation level %s is not supported", sql.IsolationLevel(opts.Isolation))
+	}
+	if c.tx != nil {
+		return nil, fmt.Errorf("lldsql: a transaction is already open")
+	}
+	c.tx = c.db.Begin()
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values, err := bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var res sqldb.Result
+	if c.tx != nil {
+		res, err = c.tx.Exec(query, values...)
+	} else {
+		res, err = c.db.Exec(query, values...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result{rowsAffected: int64(res.RowsAffected), lastInsertID: res.LastInsertID}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var rs *sqldb.ResultSet
+	if c.tx != nil {
+		rs, err = c.tx.Query(query, values...)
+	} else {
+		rs, err = c.db.Query(query, values...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rs: rs}, nil
+}
+
+// bindArgs turns the arguments of a statement into placeholder values.
+func bindArgs(args []driver.NamedValue) ([]any, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("lldsql: named argument %s is not supported, use ? placeholders", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// stmt is a prepared statement. Statements are parsed when they run, with their
+// arguments bound, so preparing only keeps the query.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, leaving the placeholder count to be checked by the parser.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	sqlTx := t.conn.tx
+	t.conn.tx = nil
+	return sqlTx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	sqlTx := t.conn.tx
+	t.conn.tx = nil
+	return sqlTx.Rollback()
+}
+
+type result struct {
+	rowsAffected int64
+	lastInsertID int64
+}
+
+// LastInsertId is the value generated for the AutoIncrement column of the last row
+// inserted, 0 when there is none.
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// rows iterates over a result set, converting values to driver.Value types.
+type rows struct {
+	rs   *sqldb.ResultSet
+	next int
+}
+
+func (r *rows) Columns() []string {
+	return r.rs.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.rs.Rows) {
+		return io.EOF
+	}
+	row := r.rs.Rows[r.next]
+	r.next++
+	for i, col := range r.rs.Columns {
+		switch v := row[col].(type) {
+		case int:
+			dest[i] = int64(v)
+		case json.RawMessage:
+			dest[i] = []byte(v)
+		default:
+			dest[i] = v
+		}
+	}
+	return nil
+}
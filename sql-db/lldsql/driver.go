@@ -0,0 +1,82 @@
+// Package lldsql registers the sqldb engine as a database/sql driver called "lldsql".
+//
+//	db, err := sql.Open("lldsql", "mem://test")
+//
+// A "mem://name" DSN names an in-memory database shared by every connection opened
+// with the same DSN in the process, so each test can use a name of its own. A
+// "file://dir" DSN opens (or creates) a persistent database in dir, see sqldb.Open.
+// Databases stay open for the life of the process. Queries use ? placeholders.
+package lldsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+func init() {
+	sql.Register("lldsql", &Driver{})
+}
+
+// Driver opens connections to the database named by a DSN.
+type Driver struct{}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*sqldb.Database) // DSN -> database
+)
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector resolves the DSN once, rather than for every new connection.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if db, ok := registry[dsn]; ok {
+		return &connector{db: db, driver: d}, nil
+	}
+	var db *sqldb.Database
+	switch {
+	case strings.HasPrefix(dsn, "mem://"):
+		db = sqldb.NewDatabase()
+	case strings.HasPrefix(dsn, "file://"):
+		var err error
+		if db, err = sqldb.Open(strings.TrimPrefix(dsn, "file://")); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("lldsql: DSN %q must start with mem:// or file://", dsn)
+	}
+	registry[dsn] = db
+	return &connector{db: db, driver: d}, nil
+}
+
+// NewConnector connects to an existing database, for use with sql.OpenDB.
+func NewConnector(db *sqldb.Database) driver.Connector {
+	return &connector{db: db, driver: &Driver{}}
+}
+
+type connector struct {
+	db     *sqldb.Database
+	driver *Driver
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,99 @@
+package lldsql_test
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/avalokitasharma/lld/sql-db/lldsql"
+)
+
+func TestRoundTripWithPlaceholders(t *testing.T) {
+	db, err := sql.Open("lldsql", "mem://"+t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE users (id INT PRIMARY KEY AUTO_INCREMENT, name VARCHAR(20) NOT NULL, score FLOAT)"); err != nil {
+		t.Fatal(err)
+	}
+	insert, err := db.Prepare("INSERT INTO users (name, score) VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer insert.Close()
+	for i, name := range []string{"ada", "alan", "bob", "alice"} {
+		res, err := insert.Exec(name, float64(i)+0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, err := res.LastInsertId(); err != nil || id != int64(i+1) {
+			t.Fatalf("LastInsertId = %d, %v, want %d", id, err, i+1)
+		}
+	}
+
+	rows, err := db.Query("SELECT id, name, score FROM users WHERE name LIKE ? AND id >= ? ORDER BY id LIMIT ? OFFSET ?", "a%", 1, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int64
+		var name string
+		var score float64
+		if err := rows.Scan(&id, &name, &score); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// ada, alan and alice match; the first is skipped
+	if len(got) != 2 || got[0] != "alan" || got[1] != "alice" {
+		t.Fatalf("got %v, want [alan alice]", got)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("UPDATE users SET score = ? WHERE name = ?", 9.5, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	var score float64
+	if err := db.QueryRow("SELECT score FROM users WHERE name = ?", "bob").Scan(&score); err != nil {
+		t.Fatal(err)
+	}
+	if score != 2.5 {
+		t.Fatalf("score = %v after rollback, want 2.5", score)
+	}
+}
+
+func TestPlaceholderArgumentErrors(t *testing.T) {
+	db, err := sql.Open("lldsql", "mem://"+t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Exec("CREATE TABLE users (id INT PRIMARY KEY, name TEXT)")
+
+	for _, tc := range []struct {
+		query string
+		args  []any
+	}{
+		{"SELECT id FROM users WHERE name LIKE ?", []any{1}},
+		{"SELECT id FROM users LIMIT ?", []any{-1}},
+		{"SELECT id FROM users LIMIT ?", []any{"ten"}},
+		{"SELECT id FROM users WHERE id = ?", nil},
+	} {
+		if rows, err := db.Query(tc.query, tc.args...); err == nil {
+			rows.Close()
+			t.Errorf("%s with %v succeeded", tc.query, tc.args)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package sqldb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// rowVersion is one version of a row. A version is never modified after it is
+// published, apart from xmax which is set once a later commit replaces or deletes it.
+type rowVersion struct {
+	id   int64 // stable id shared by every version of the same row
+	data map[string]any
+	xmin uint64        // commit that created this version
+	xmax atomic.Uint64 // commit that superseded this version, 0 while it is current
+}
+
+func (v *rowVersion) visible(snapshot uint64) bool {
+	if v.xmin > snapshot {
+		return false
+	}
+	xmax := v.xmax.Load()
+	return xmax == 0 || xmax > snapshot
+}
+
+// txManager hands out snapshots and orders commits for every table of a database.
+//
+// Commits are numbered; a snapshot is the number of the last published commit and
+// sees exactly the versions created at or before it. Writers of different tables
+// only meet in commitMu while a validated commit is applied, which keeps commit
+// numbers published in order.
+type txManager struct {
+	committed atomic.Uint64
+	commitMu  sync.Mutex
+
+	snapMu    sync.Mutex
+	snapshots map[uint64]int // active snapshot -> number of readers using it
+
+	log  *wal // nil for in-memory databases
+	feed changeFeed
+}
+
+func newTxManager() *txManager {
+	return &txManager{snapshots: make(map[uint64]int)}
+}
+
+// acquireSnapshot pins the latest commit so vacuum keeps the versions it can see.
+// Every call must be paired with releaseSnapshot.
+func (tm *txManager) acquireSnapshot() uint64 {
+	tm.snapMu.Lock()
+	defer tm.snapMu.Unlock()
+	snapshot := tm.committed.Load()
+	tm.snapshots[snapshot]++
+	return snapshot
+}
+
+func (tm *txManager) releaseSnapshot(snapshot uint64) {
+	tm.snapMu.Lock()
+	defer tm.snapMu.Unlock()
+	if tm.snapshots[snapshot]--; tm.snapshots[snapshot] <= 0 {
+		delete(tm.snapshots, snapshot)
+	}
+}
+
+// horizon is the oldest snapshot still in use; versions superseded at or before it
+// are invisible to every reader.
+func (tm *txManager) horizon() uint64 {
+	tm.snapMu.Lock()
+	defer tm.snapMu.Unlock()
+	oldest := tm.committed.Load()
+	for snapshot := range tm.snapshots {
+		if snapshot < oldest {
+			oldest = snapshot
+		}
+	}
+	return oldest
+}
+
+// commit validates and publishes the write sets. The caller must hold the write
+// lock of every table involved.
+func (tm *txManager) commit(sets []*writeSet) error {
+	for _, ws := range sets {
+		if err := ws.checkConflicts(); err != nil {
+			return err
+		}
+	}
+
+	var rec *walRecord
+	if tm.log != nil {
+		var err error
+		if rec, err = commitRecord(sets); err != nil {
+			return err
+		}
+	}
+
+	tm.commitMu.Lock()
+	// log in commit order, before the changes become visible
+	if rec != nil {
+		if err := tm.log.append(rec); err != nil {
+			tm.commitMu.Unlock()
+			return err
+		}
+	}
+	seq := tm.committed.Load() + 1
+	for _, ws := range sets {
+		ws.apply(seq)
+	}
+	tm.committed.Store(seq)
+	tm.feed.publish(seq, sets)
+	tm.commitMu.Unlock()
+
+	for _, ws := range sets {
+		ws.table.maybeVacuum()
+	}
+	return nil
+}
+
+// pendingRow is an uncommitted change to one row.
+type pendingRow struct {
+	data map[string]any // new row data, nil when the row is deleted
+	base *rowVersion    // committed version the change replaces, nil for new rows
+}
+
+// writeSet collects the uncommitted changes to one table.
+type writeSet struct {
+	table *Table
+	rows  map[int64]*pendingRow
+	undo  []undoEntry // changes of the current statement, see Tx.statement
+}
+
+// undoEntry is the state of a row in the write set before a change.
+type undoEntry struct {
+	id   int64
+	prev *pendingRow // nil if the write set didn't hold the row
+}
+
+func (ws *writeSet) put(id int64, p *pendingRow) {
+	ws.undo = append(ws.undo, undoEntry{id: id, prev: ws.rows[id]})
+	ws.rows[id] = p
+}
+
+func (ws *writeSet) remove(id int64) {
+	ws.undo = append(ws.undo, undoEntry{id: id, prev: ws.rows[id]})
+	delete(ws.rows, id)
+}
+
+// rollbackTo reverts the changes recorded in undo past mark, mark being 0 for the
+// changes made since the last commitStatement.
+func (ws *writeSet) rollbackTo(mark int) {
+	for i := len(ws.undo) - 1; i >= mark; i-- {
+		entry := ws.undo[i]
+		if entry.prev == nil {
+			delete(ws.rows, entry.id)
+		} else {
+			ws.rows[entry.id] = entry.prev
+		}
+	}
+	ws.undo = ws.undo[:mark]
+}
+
+func (ws *writeSet) commitStatement() {
+	ws.undo = ws.undo[:0]
+}
+
+func newWriteSet(t *Table) *writeSet {
+	return &writeSet{table: t, rows: make(map[int64]*pendingRow)}
+}
+
+// insert validates rows against the snapshot plus this write set and adds them to it,
+// returning their ids. before, if not nil, runs the before triggers on each row.
+func (ws *writeSet) insert(snapshot uint64, rows []map[string]any, before func(*RowChange) error) ([]int64, error) {
+	t := ws.table
+	prepared := make(map[int64]map[string]any, len(rows))
+	var ids []int64
+	for _, r := range rows {
+		generated, err := t.generate(r)
+		if err != nil {
+			return nil, err
+		}
+		if before != nil {
+			change := &RowChange{Table: t.Name, Op: ChangeInsert, New: generated}
+			if err := before(change); err != nil {
+				return nil, err
+			}
+			generated = change.New
+			t.compute(generated)
+		}
+		safeCopy, err := t.prepareRow(generated)
+		if err != nil {
+			return nil, err
+		}
+		id := t.nextID.Add(1)
+		prepared[id] = safeCopy
+		ids = append(ids, id)
+	}
+	if err := ws.checkUnique(snapshot, prepared); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		ws.put(id, &pendingRow{data: prepared[id]})
+	}
+	return ids, nil
+}
+
+// update applies changes to the rows matching pred and returns the rows as they were
+// before. before, if not nil, runs the before triggers on each updated row.
+func (ws *writeSet) update(snapshot uint64, pred Predicate, changes map[string]any, before func(*RowChange) error) ([]rowRef, error) {
+	t := ws.table
+	for colName := range changes {
+		col := t.GetColumn(colName)
+		if col == nil {
+			return nil, unknownColumn(colName)
+		}
+		if col.Constraints.Computed != nil {
+			return nil, fmt.Errorf("column %s is computed and can't be set", colName)
+		}
+	}
+
+	matched := t.scan(snapshot, pred, ws)
+	updated := make(map[int64]map[string]any, len(matched))
+	for _, ref := range matched {
+		newRow := make(map[string]any, len(ref.data)+len(changes))
+		for col, value := range ref.data {
+			newRow[col] = value
+		}
+		for col, value := range changes {
+			newRow[col] = value
+		}
+		if before != nil {
+			change := &RowChange{Table: t.Name, Op: ChangeUpdate, Old: copyRow(ref.data), New: newRow}
+			if err := before(change); err != nil {
+				return nil, err
+			}
+			newRow = change.New
+		}
+		t.compute(newRow)
+		prepared, err := t.prepareRow(newRow)
+		if err != nil {
+			return nil, err
+		}
+		updated[ref.id] = prepared
+	}
+	if err := ws.checkUnique(snapshot, updated); err != nil {
+		return nil, err
+	}
+	for _, ref := range matched {
+		ws.put(ref.id, &pendingRow{data: updated[ref.id], base: ref.version})
+	}
+	return matched, nil
+}
+
+// delete removes the rows matching pred and returns them. before, if not nil, runs
+// the before triggers on each row, all of them before any row is removed.
+func (ws *writeSet) delete(snapshot uint64, pred Predicate, before func(*RowChange) error) ([]rowRef, error) {
+	matched := ws.table.scan(snapshot, pred, ws)
+	for _, ref := range matched {
+		if before == nil {
+			break
+		}
+		change := &RowChange{Table: ws.table.Name, Op: ChangeDelete, Old: copyRow(ref.data)}
+		if err := before(change); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range matched {
+		if ref.version == nil {
+			// the row only exists in this write set
+			ws.remove(ref.id)
+			continue
+		}
+		ws.put(ref.id, &pendingRow{base: ref.version})
+	}
+	return matched, nil
+}
+
+// checkUnique makes sure the given rows, keyed by row id, don't repeat a unique value
+// among themselves or with any other row visible in the snapshot plus this write set.
+// It reports every unique value of the first row that repeats one.
+func (ws *writeSet) checkUnique(snapshot uint64, rows map[int64]map[string]any) error {
+	t := ws.table
+	var unique []*Column
+	for _, col := range t.Columns {
+		if col.Constraints.Unique {
+			unique = append(unique, col)
+		}
+	}
+	seen := make(map[string]map[any]bool, len(unique))
+	for _, col := range unique {
+		seen[col.Name] = make(map[any]bool)
+	}
+	for _, id := range sortedIDs(rows) {
+		var violations []error
+		for _, col := range unique {
+			val := rows[id][col.Name]
+			if val == nil {
+				continue
+			}
+			key := t.index(col.Name).key(val)
+			duplicate := seen[col.Name][key]
+			seen[col.Name][key] = true
+			for _, other := range t.scan(snapshot, Eq(col.Name, val), ws) {
+				if _, replaced := rows[other.id]; !replaced {
+					duplicate = true
+				}
+			}
+			if duplicate {
+				violations = append(violations, duplicateKey(t, col, val))
+			}
+		}
+		if len(violations) > 0 {
+			return errors.Join(violations...)
+		}
+	}
+	return nil
+}
+
+func duplicateKey(t *Table, col *Column, val any) error {
+	return col.violation(t.Name, ConstraintUnique, val, "duplicate key value %v", val)
+}
+
+// checkConflicts runs at commit, under the table's write lock. It fails if a row this
+// write set changes was changed by a commit after the write set read it, or if a new
+// value collides with a unique value committed in the meantime.
+func (ws *writeSet) checkConflicts() error {
+	t := ws.table
+	for id, p := range ws.rows {
+		if p.base != nil && p.base.xmax.Load() != 0 {
+			return fmt.Errorf("transaction conflict: row %d of table %s was changed by another transaction", id, t.Name)
+		}
+	}
+	for _, col := range t.Columns {
+		if !col.Constraints.Unique {
+			continue
+		}
+		idx := t.index(col.Name)
+		for _, p := range ws.rows {
+			val := p.data[col.Name]
+			if val == nil {
+				continue
+			}
+			for _, v := range t.lookupIndex(idx, val) {
+				if _, replaced := ws.rows[v.id]; v.xmax.Load() == 0 && !replaced {
+					return duplicateKey(t, col, val)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// apply publishes the write set as commit seq and returns the versions it added.
+func (ws *writeSet) apply(seq uint64) []*rowVersion {
+	t := ws.table
+	versions := *t.versions.Load()
+	var added []*rowVersion
+	for _, id := range sortedIDs(ws.rows) {
+		p := ws.rows[id]
+		if p.base != nil {
+			p.base.xmax.Store(seq)
+			t.dead++
+		}
+		if p.data != nil {
+			v := &rowVersion{id: id, data: p.data, xmin: seq}
+			versions = append(versions, v)
+			added = append(added, v)
+			t.observeAutoIncrement(p.data)
+		}
+	}
+
+	t.idxMu.Lock()
+	for _, idx := range t.indexes {
+		for _, v := range added {
+			idx.insert(v)
+		}
+	}
+	t.idxMu.Unlock()
+	t.versions.Store(&versions)
+	return added
+}
+
+func sortedIDs[T any](rows map[int64]T) []int64 {
+	ids := make([]int64, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,113 @@
+package sqldb
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadersDoNotWaitForWriters(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	if err := db.InsertRecord("users", map[string]any{"id": 1, "name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("users")
+	// hold the write lock the way a long statement does
+	table.mu.Lock()
+	defer table.mu.Unlock()
+
+	done := make(chan int)
+	go func() {
+		rows, _ := db.GetRecords("users", nil)
+		done <- len(rows)
+	}()
+	select {
+	case n := <-done:
+		if n != 1 {
+			t.Fatalf("reader got %d rows, want 1", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader blocked behind the write lock")
+	}
+}
+
+func TestVacuumKeepsVersionsOfOpenSnapshots(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	if err := db.InsertRecord("users", map[string]any{"id": 1, "name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	reader := db.Begin()
+	if _, err := db.UpdateRecords("users", nil, map[string]any{"name": "grace"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DeleteRecords("users", nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Vacuum()
+	row, err := reader.GetByPrimaryKey("users", 1)
+	if err != nil || row["name"] != "ada" {
+		t.Fatalf("open snapshot reads %v, %v after vacuum, want ada", row, err)
+	}
+	reader.Rollback()
+
+	db.Vacuum()
+	table, _ := db.GetTable("users")
+	if n := len(*table.versions.Load()); n != 0 {
+		t.Fatalf("%d versions left once no snapshot needs them", n)
+	}
+}
+
+func TestReadersSeeWholeCommits(t *testing.T) {
+	db := NewDatabase()
+	err := db.CreateTable("accounts", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("balance", TypeInt),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.InsertMany("accounts", []map[string]any{{"id": 1, "balance": 100}, {"id": 2, "balance": 0}})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		// move one unit at a time from the first account to the second
+		defer wg.Done()
+		defer close(stop)
+		for i := 0; i < 100; i++ {
+			tx := db.Begin()
+			tx.UpdateRecords("accounts", map[string]any{"id": 1}, map[string]any{"balance": 99 - i})
+			tx.UpdateRecords("accounts", map[string]any{"id": 2}, map[string]any{"balance": i + 1})
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				rows, _ := db.GetRecords("accounts", nil)
+				var total int64
+				for _, row := range rows {
+					total += row["balance"].(int64)
+				}
+				if len(rows) != 2 || total != 100 {
+					t.Errorf("reader saw %v, a commit in part", rows)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,1148 @@
+package sqldb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Statement interface {
+	statement()
+}
+
+type CreateTableStmt struct {
+	Table   string
+	Columns []*Column
+}
+
+type DropTableStmt struct {
+	Table string
+}
+
+type CreateIndexStmt struct {
+	Table  string
+	Column string
+	Kind   IndexKind
+}
+
+type AlterTableStmt struct {
+	Table       string
+	Alterations []Alteration
+}
+
+type DropIndexStmt struct {
+	Table  string
+	Column string
+}
+
+type InsertStmt struct {
+	Table   string
+	Columns []string // empty means every column in table order
+	Values  [][]any
+}
+
+type SelectStmt struct {
+	Table   string
+	Columns []string // empty means *
+	Where   Predicate
+	OrderBy []OrderBy
+	Limit   *int // nil means no limit
+	Offset  int
+
+	GroupBy    []string
+	Aggregates []Aggregate // includes aggregates only used in HAVING or ORDER BY
+	Having     Predicate
+
+	Joins []Join
+}
+
+// ExplainStmt runs a SELECT and returns the plan it followed instead of its rows.
+type ExplainStmt struct {
+	Select *SelectStmt
+}
+
+type UpdateStmt struct {
+	Table string
+	Set   map[string]any
+	Where Predicate
+}
+
+type DeleteStmt struct {
+	Table string
+	Where Predicate
+}
+
+func (*CreateTableStmt) statement() {}
+func (*DropTableStmt) statement()   {}
+func (*CreateIndexStmt) statement() {}
+func (*DropIndexStmt) statement()   {}
+func (*AlterTableStmt) statement()  {}
+func (*InsertStmt) statement()      {}
+func (*SelectStmt) statement()      {}
+func (*ExplainStmt) statement()     {}
+func (*UpdateStmt) statement()      {}
+func (*DeleteStmt) statement()      {}
+
+type parser struct {
+	tokens []token
+	pos    int
+
+	// aggregates of the SELECT being parsed, set while parsing HAVING and ORDER BY
+	// where aggregate calls may appear
+	aggregates *[]Aggregate
+
+	args    []any // values of the ? placeholders
+	nextArg int
+}
+
+// Parse parses one or more ';' separated statements. Each ? placeholder where a
+// literal value may appear takes the next of args, in order.
+func Parse(query string, args ...any) ([]Statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, args: args}
+
+	var stmts []Statement
+	for {
+		for p.acceptSymbol(";") {
+		}
+		if p.peek().kind == tokEOF {
+			break
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if !p.acceptSymbol(";") && p.peek().kind != tokEOF {
+			return nil, p.errorf("expected ';' or end of query")
+		}
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	if p.nextArg != len(args) {
+		return nil, fmt.Errorf("query has %d placeholders but %d arguments were given", p.nextArg, len(args))
+	}
+	return stmts, nil
+}
+
+func (p *parser) parseStatement() (Statement, error) {
+	tok := p.peek()
+	if tok.kind != tokKeyword {
+		return nil, p.errorf("expected a statement")
+	}
+	switch tok.text {
+	case "CREATE":
+		if next := p.tokens[p.pos+1]; next.kind == tokKeyword && next.text == "INDEX" {
+			return p.parseCreateIndex()
+		}
+		return p.parseCreateTable()
+	case "DROP":
+		if next := p.tokens[p.pos+1]; next.kind == tokKeyword && next.text == "INDEX" {
+			return p.parseDropIndex()
+		}
+		return p.parseDropTable()
+	case "ALTER":
+		return p.parseAlterTable()
+	case "INSERT":
+		return p.parseInsert()
+	case "SELECT":
+		return p.parseSelect()
+	case "EXPLAIN":
+		p.pos++
+		stmt, err := p.parseSelect()
+		if err != nil {
+			return nil, err
+		}
+		return &ExplainStmt{Select: stmt.(*SelectStmt)}, nil
+	case "UPDATE":
+		return p.parseUpdate()
+	case "DELETE":
+		return p.parseDelete()
+	}
+	return nil, p.errorf("unsupported statement %s", tok.text)
+}
+
+func (p *parser) parseCreateTable() (Statement, error) {
+	if err := p.expectKeywords("CREATE", "TABLE"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	stmt := &CreateTableStmt{Table: name}
+	for {
+		col, err := p.parseColumnDef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, col)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseColumnDef() (*Column, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	typeName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	var constraints []func(*ColumnConstraint)
+	var colType ColumnType
+	switch strings.ToUpper(typeName) {
+	case "INT", "INTEGER", "BIGINT", "SMALLINT":
+		colType = TypeInt
+	case "STRING", "TEXT", "VARCHAR", "CHAR":
+		colType = TypeString
+		if p.acceptSymbol("(") {
+			length, err := p.expectInt()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, MaxLength(int(length)))
+		}
+	case "FLOAT", "REAL", "DOUBLE", "DECIMAL", "NUMERIC":
+		colType = TypeFloat
+	case "BOOL", "BOOLEAN":
+		colType = TypeBool
+	case "TIMESTAMP", "DATETIME", "DATE":
+		colType = TypeTimestamp
+	case "BYTES", "BLOB", "BYTEA":
+		colType = TypeBytes
+	case "JSON":
+		colType = TypeJSON
+	default:
+		return nil, p.errorf("unknown column type %s", typeName)
+	}
+
+	for {
+		switch {
+		case p.acceptKeyword("NOT"):
+			if err := p.expectKeywords("NULL"); err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, Required())
+		case p.acceptKeyword("NULL"):
+			// nullable is the default
+		case p.acceptKeyword("PRIMARY"):
+			if err := p.expectKeywords("KEY"); err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, PrimaryKey())
+		case p.acceptKeyword("UNIQUE"):
+			constraints = append(constraints, Unique())
+		case p.acceptKeyword("REFERENCES"):
+			constraint, err := p.parseReferences()
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, constraint)
+		case p.acceptKeyword("CHECK"):
+			constraint, err := p.parseCheck(name)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, constraint)
+		case p.acceptKeyword("DEFAULT"):
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, Default(value))
+		case p.acceptKeyword("AUTO_INCREMENT"), p.acceptKeyword("AUTOINCREMENT"):
+			constraints = append(constraints, AutoIncrement())
+		default:
+			return NewColumn(name, colType, constraints...), nil
+		}
+	}
+}
+
+// parseReferences parses the rest of REFERENCES table [ON DELETE CASCADE | SET NULL | RESTRICT].
+func (p *parser) parseReferences() (func(*ColumnConstraint), error) {
+	table, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	onDelete := Restrict
+	if p.acceptKeyword("ON") {
+		if err := p.expectKeywords("DELETE"); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.acceptKeyword("CASCADE"):
+			onDelete = Cascade
+		case p.acceptKeyword("SET"):
+			if err := p.expectKeywords("NULL"); err != nil {
+				return nil, err
+			}
+			onDelete = SetNull
+		case p.acceptKeyword("RESTRICT"):
+		default:
+			return nil, p.errorf("expected CASCADE, SET NULL or RESTRICT")
+		}
+	}
+	return References(table, onDelete), nil
+}
+
+// parseCheck supports the column CHECK forms that map onto ColumnConstraint:
+// col >= n, col <= n, col IN (...), col ~ 'regex', LENGTH(col) >= n and LENGTH(col) <= n,
+// where n is an integer.
+func (p *parser) parseCheck(colName string) (func(*ColumnConstraint), error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	ref, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	length := strings.EqualFold(ref, "LENGTH") && p.acceptSymbol("(")
+	if length {
+		if ref, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if ref != colName {
+		return nil, p.errorf("CHECK on column %s must reference %s", colName, colName)
+	}
+
+	var constraint func(*ColumnConstraint)
+	switch {
+	case p.acceptSymbol(">="):
+		n, err := p.expectCheckBound()
+		if err != nil {
+			return nil, err
+		}
+		constraint = MinValue(int(n))
+		if length {
+			constraint = MinLength(int(n))
+		}
+	case p.acceptSymbol("<="):
+		n, err := p.expectCheckBound()
+		if err != nil {
+			return nil, err
+		}
+		constraint = MaxValue(int(n))
+		if length {
+			constraint = MaxLength(int(n))
+		}
+	case !length && p.acceptKeyword("IN"):
+		values, err := p.parseLiteralList()
+		if err != nil {
+			return nil, err
+		}
+		constraint = Enum(values...)
+	case !length && p.acceptSymbol("~"):
+		tok := p.peek()
+		if tok.kind != tokString {
+			return nil, p.errorf("expected pattern string after ~")
+		}
+		p.pos++
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", tok.text, err)
+		}
+		constraint = func(cc *ColumnConstraint) { cc.Pattern = re }
+	default:
+		return nil, p.errorf("unsupported CHECK expression")
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return constraint, nil
+}
+
+// expectCheckBound parses the bound of a CHECK comparison. MinValue, MaxValue and the
+// length constraints hold ints, so fractional bounds are rejected rather than rounded.
+func (p *parser) expectCheckBound() (int64, error) {
+	tok := p.peek()
+	if tok.kind == tokSymbol && tok.text == "-" && p.pos+1 < len(p.tokens) {
+		tok = p.tokens[p.pos+1]
+	}
+	if tok.kind == tokNumber && strings.ContainsAny(tok.text, ".eE") {
+		return 0, p.errorf("CHECK bounds must be integers")
+	}
+	return p.expectInt()
+}
+
+func (p *parser) parseDropTable() (Statement, error) {
+	if err := p.expectKeywords("DROP", "TABLE"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	return &DropTableStmt{Table: name}, nil
+}
+
+// parseAlterTable parses ALTER TABLE table followed by a comma separated list of
+// ADD [COLUMN] definition [DEFAULT literal], DROP [COLUMN] name,
+// RENAME [COLUMN] name TO new_name and MODIFY [COLUMN] definition.
+func (p *parser) parseAlterTable() (Statement, error) {
+	if err := p.expectKeywords("ALTER", "TABLE"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &AlterTableStmt{Table: name}
+	for {
+		alteration, err := p.parseAlteration()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Alterations = append(stmt.Alterations, alteration)
+		if !p.acceptSymbol(",") {
+			return stmt, nil
+		}
+	}
+}
+
+func (p *parser) parseAlteration() (Alteration, error) {
+	switch {
+	case p.acceptKeyword("ADD"):
+		p.acceptKeyword("COLUMN")
+		// existing rows get the column's DEFAULT
+		col, err := p.parseColumnDef()
+		if err != nil {
+			return nil, err
+		}
+		return AddColumn(col, nil), nil
+	case p.acceptKeyword("DROP"):
+		p.acceptKeyword("COLUMN")
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		return DropColumn(name), nil
+	case p.acceptKeyword("RENAME"):
+		p.acceptKeyword("COLUMN")
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeywords("TO"); err != nil {
+			return nil, err
+		}
+		newName, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		return RenameColumn(name, newName), nil
+	case p.acceptKeyword("MODIFY"):
+		p.acceptKeyword("COLUMN")
+		col, err := p.parseColumnDef()
+		if err != nil {
+			return nil, err
+		}
+		return ModifyColumn(col), nil
+	}
+	return nil, p.errorf("expected ADD, DROP, RENAME or MODIFY")
+}
+
+// parseCreateIndex parses CREATE INDEX ON table (column) [USING HASH | ORDERED | BTREE].
+func (p *parser) parseCreateIndex() (Statement, error) {
+	if err := p.expectKeywords("CREATE", "INDEX", "ON"); err != nil {
+		return nil, err
+	}
+	table, column, err := p.parseIndexTarget()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateIndexStmt{Table: table, Column: column, Kind: HashIndex}
+	if p.acceptKeyword("USING") {
+		kind, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(kind) {
+		case "HASH":
+			stmt.Kind = HashIndex
+		case "ORDERED", "BTREE":
+			stmt.Kind = OrderedIndex
+		default:
+			return nil, p.errorf("unknown index kind %s", kind)
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseDropIndex() (Statement, error) {
+	if err := p.expectKeywords("DROP", "INDEX", "ON"); err != nil {
+		return nil, err
+	}
+	table, column, err := p.parseIndexTarget()
+	if err != nil {
+		return nil, err
+	}
+	return &DropIndexStmt{Table: table, Column: column}, nil
+}
+
+func (p *parser) parseIndexTarget() (string, string, error) {
+	table, err := p.expectIdent()
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return "", "", err
+	}
+	column, err := p.expectIdent()
+	if err != nil {
+		return "", "", err
+	}
+	return table, column, p.expectSymbol(")")
+}
+
+func (p *parser) parseInsert() (Statement, error) {
+	if err := p.expectKeywords("INSERT", "INTO"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &InsertStmt{Table: name}
+	if p.acceptSymbol("(") {
+		if stmt.Columns, err = p.parseIdentList(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeywords("VALUES"); err != nil {
+		return nil, err
+	}
+	for {
+		values, err := p.parseLiteralList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Values = append(stmt.Values, values)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseSelect() (Statement, error) {
+	if err := p.expectKeywords("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt := &SelectStmt{}
+	if !p.acceptSymbol("*") {
+		if err := p.parseSelectList(stmt); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeywords("FROM"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = name
+	if stmt.Joins, err = p.parseJoins(); err != nil {
+		return nil, err
+	}
+	if stmt.Where, err = p.parseWhere(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeywords("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.GroupBy, err = p.parseIdentList(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.aggregates = &stmt.Aggregates
+	defer func() { p.aggregates = nil }()
+	if p.acceptKeyword("HAVING") {
+		if stmt.Having, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.OrderBy, err = p.parseOrderBy(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("LIMIT") {
+		n, err := p.expectCount()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Limit = &n
+	}
+	if p.acceptKeyword("OFFSET") {
+		if stmt.Offset, err = p.expectCount(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseJoins parses [INNER | LEFT [OUTER]] JOIN table ON a.x = b.y clauses.
+func (p *parser) parseJoins() ([]Join, error) {
+	var joins []Join
+	for {
+		join := Join{Kind: InnerJoin}
+		switch {
+		case p.acceptKeyword("LEFT"):
+			p.acceptKeyword("OUTER")
+			join.Kind = LeftJoin
+		case p.acceptKeyword("INNER"):
+		case p.peek().kind == tokKeyword && p.peek().text == "JOIN":
+		default:
+			return joins, nil
+		}
+		if err := p.expectKeywords("JOIN"); err != nil {
+			return nil, err
+		}
+		var err error
+		if join.Table, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeywords("ON"); err != nil {
+			return nil, err
+		}
+		if join.On, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		if join.To, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+		joins = append(joins, join)
+	}
+}
+
+// parseSelectList parses the result columns of a SELECT: column names and aggregate
+// calls such as COUNT(*) or SUM(price) AS total.
+func (p *parser) parseSelectList(stmt *SelectStmt) error {
+	for {
+		name, err := p.expectIdent()
+		if err != nil {
+			return err
+		}
+		if p.peek().kind == tokSymbol && p.peek().text == "(" {
+			agg, err := p.parseAggregateCall(name)
+			if err != nil {
+				return err
+			}
+			if p.acceptKeyword("AS") {
+				if agg.Alias, err = p.expectIdent(); err != nil {
+					return err
+				}
+			}
+			stmt.Aggregates = append(stmt.Aggregates, agg)
+			name = agg.Name()
+		}
+		stmt.Columns = append(stmt.Columns, name)
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseAggregateCall(name string) (Aggregate, error) {
+	agg := Aggregate{Func: AggregateFunc(strings.ToUpper(name))}
+	switch agg.Func {
+	case AggCount, AggSum, AggMin, AggMax, AggAvg:
+	default:
+		return agg, p.errorf("unknown function %s", name)
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return agg, err
+	}
+	if agg.Func == AggCount && p.acceptSymbol("*") {
+		agg.Column = "*"
+	} else {
+		column, err := p.expectIdent()
+		if err != nil {
+			return agg, err
+		}
+		agg.Column = column
+	}
+	return agg, p.expectSymbol(")")
+}
+
+// parseColumnRef parses a column name or, in HAVING and ORDER BY, an aggregate call,
+// which stands for the aggregate's result column.
+func (p *parser) parseColumnRef() (string, error) {
+	name, err := p.expectIdent()
+	if err != nil || p.aggregates == nil || p.peek().kind != tokSymbol || p.peek().text != "(" {
+		return name, err
+	}
+	agg, err := p.parseAggregateCall(name)
+	if err != nil {
+		return "", err
+	}
+	for _, existing := range *p.aggregates {
+		if existing.Func == agg.Func && existing.Column == agg.Column {
+			return existing.Name(), nil
+		}
+	}
+	*p.aggregates = append(*p.aggregates, agg)
+	return agg.Name(), nil
+}
+
+// parseOrderBy parses an optional ORDER BY col [ASC | DESC], ... clause.
+func (p *parser) parseOrderBy() ([]OrderBy, error) {
+	if !p.acceptKeyword("ORDER") {
+		return nil, nil
+	}
+	if err := p.expectKeywords("BY"); err != nil {
+		return nil, err
+	}
+	var orderBy []OrderBy
+	for {
+		column, err := p.parseColumnRef()
+		if err != nil {
+			return nil, err
+		}
+		order := OrderBy{Column: column}
+		if p.acceptKeyword("DESC") {
+			order.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		orderBy = append(orderBy, order)
+		if !p.acceptSymbol(",") {
+			return orderBy, nil
+		}
+	}
+}
+
+// expectCount parses the non-negative count of LIMIT and OFFSET, a number or a
+// placeholder bound to one.
+func (p *parser) expectCount() (int, error) {
+	if p.atPlaceholder() {
+		arg, err := p.parsePlaceholder()
+		if err != nil {
+			return 0, err
+		}
+		n, err := convertToInt(arg)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("placeholder %d: expected a non-negative count but got %v", p.nextArg, arg)
+		}
+		return int(n), nil
+	}
+	n, err := p.expectInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, p.errorf("expected a non-negative count")
+	}
+	return int(n), nil
+}
+
+func (p *parser) parseUpdate() (Statement, error) {
+	if err := p.expectKeywords("UPDATE"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeywords("SET"); err != nil {
+		return nil, err
+	}
+	stmt := &UpdateStmt{Table: name, Set: make(map[string]any)}
+	for {
+		col, val, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Set[col] = val
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if stmt.Where, err = p.parseWhere(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseDelete() (Statement, error) {
+	if err := p.expectKeywords("DELETE", "FROM"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &DeleteStmt{Table: name}
+	if stmt.Where, err = p.parseWhere(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseWhere() (Predicate, error) {
+	if !p.acceptKeyword("WHERE") {
+		return nil, nil
+	}
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	preds := OrPredicate{left}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, right)
+	}
+	if len(preds) == 1 {
+		return left, nil
+	}
+	return preds, nil
+}
+
+func (p *parser) parseAnd() (Predicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	preds := AndPredicate{left}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, right)
+	}
+	if len(preds) == 1 {
+		return left, nil
+	}
+	return preds, nil
+}
+
+func (p *parser) parseNot() (Predicate, error) {
+	if p.acceptKeyword("NOT") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not(inner), nil
+	}
+	if p.acceptSymbol("(") {
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return pred, p.expectSymbol(")")
+	}
+	return p.parseCondition()
+}
+
+// parseCondition parses a single column test such as id >= 10, name LIKE 'a%',
+// id IN (1, 2) or note IS NOT NULL.
+func (p *parser) parseCondition() (Predicate, error) {
+	col, err := p.parseColumnRef()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if err := p.expectKeywords("NULL"); err != nil {
+			return nil, err
+		}
+		if negate {
+			return NotNull(col), nil
+		}
+		return Null(col), nil
+	}
+
+	negate := p.acceptKeyword("NOT")
+	var pred Predicate
+	switch {
+	case p.acceptKeyword("IN"):
+		values, err := p.parseLiteralList()
+		if err != nil {
+			return nil, err
+		}
+		pred = In(col, values...)
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parsePattern()
+		if err != nil {
+			return nil, err
+		}
+		pred = LikePattern(col, pattern)
+	case negate:
+		return nil, p.errorf("expected IN or LIKE after NOT")
+	default:
+		op, err := p.parseCompareOp()
+		if err != nil {
+			return nil, err
+		}
+		val, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		pred = &Comparison{Column: col, Op: op, Value: val}
+	}
+	if negate {
+		return Not(pred), nil
+	}
+	return pred, nil
+}
+
+// parsePattern parses the pattern of LIKE, a string or a placeholder bound to one.
+func (p *parser) parsePattern() (string, error) {
+	if p.atPlaceholder() {
+		arg, err := p.parsePlaceholder()
+		if err != nil {
+			return "", err
+		}
+		pattern, ok := arg.(string)
+		if !ok {
+			return "", fmt.Errorf("placeholder %d: expected pattern string but got %T", p.nextArg, arg)
+		}
+		return pattern, nil
+	}
+	tok := p.peek()
+	if tok.kind != tokString {
+		return "", p.errorf("expected pattern string after LIKE")
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+func (p *parser) parseCompareOp() (CompareOp, error) {
+	tok := p.peek()
+	if tok.kind == tokSymbol {
+		switch tok.text {
+		case "=", "!=", "<", "<=", ">", ">=":
+			p.pos++
+			return CompareOp(tok.text), nil
+		case "<>":
+			p.pos++
+			return OpNe, nil
+		}
+	}
+	return "", p.errorf("expected comparison operator")
+}
+
+func (p *parser) parseAssignment() (string, any, error) {
+	col, err := p.expectIdent()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := p.expectSymbol("="); err != nil {
+		return "", nil, err
+	}
+	val, err := p.parseLiteral()
+	if err != nil {
+		return "", nil, err
+	}
+	return col, val, nil
+}
+
+func (p *parser) parseLiteral() (any, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokString:
+		p.pos++
+		return tok.text, nil
+	case tok.kind == tokBytes:
+		p.pos++
+		return []byte(tok.text), nil
+	case tok.kind == tokNumber, tok.kind == tokSymbol && tok.text == "-":
+		return p.parseNumber()
+	case tok.kind == tokKeyword && tok.text == "NULL":
+		p.pos++
+		return nil, nil
+	case tok.kind == tokKeyword && (tok.text == "TRUE" || tok.text == "FALSE"):
+		p.pos++
+		return tok.text == "TRUE", nil
+	case tok.kind == tokSymbol && tok.text == "?":
+		return p.parsePlaceholder()
+	}
+	return nil, p.errorf("expected a literal value")
+}
+
+// parsePlaceholder binds the ? at the current position to the next argument.
+func (p *parser) parsePlaceholder() (any, error) {
+	if p.nextArg == len(p.args) {
+		return nil, p.errorf("no argument for placeholder %d", p.nextArg+1)
+	}
+	p.pos++
+	p.nextArg++
+	return p.args[p.nextArg-1], nil
+}
+
+func (p *parser) atPlaceholder() bool {
+	tok := p.peek()
+	return tok.kind == tokSymbol && tok.text == "?"
+}
+
+// parseLiteralList parses a parenthesised, comma separated list of literals.
+func (p *parser) parseLiteralList() ([]any, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var values []any
+	for {
+		val, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	return values, p.expectSymbol(")")
+}
+
+func (p *parser) parseIdentList() ([]string, error) {
+	var idents []string
+	for {
+		ident, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		idents = append(idents, ident)
+		if !p.acceptSymbol(",") {
+			return idents, nil
+		}
+	}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if tok := p.peek(); tok.kind == tokKeyword && tok.text == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptSymbol(sym string) bool {
+	if tok := p.peek(); tok.kind == tokSymbol && tok.text == sym {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeywords(kws ...string) error {
+	for _, kw := range kws {
+		if !p.acceptKeyword(kw) {
+			return p.errorf("expected %s", kw)
+		}
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(sym string) error {
+	if !p.acceptSymbol(sym) {
+		return p.errorf("expected '%s'", sym)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokIdent {
+		return "", p.errorf("expected identifier")
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+// parseNumber parses an int, or a float64 when the literal has a fraction or exponent.
+func (p *parser) parseNumber() (any, error) {
+	neg := p.peek().kind == tokSymbol && p.peek().text == "-"
+	tok := p.tokens[p.pos]
+	if neg {
+		tok = p.tokens[p.pos+1]
+	}
+	if tok.kind == tokNumber && strings.ContainsAny(tok.text, ".eE") {
+		p.pos++
+		if neg {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", tok.text, tok.pos)
+		}
+		if neg {
+			f = -f
+		}
+		return f, nil
+	}
+	n, err := p.expectInt()
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *parser) expectInt() (int64, error) {
+	neg := p.acceptSymbol("-")
+	tok := p.peek()
+	if tok.kind != tokNumber {
+		return 0, p.errorf("expected number")
+	}
+	p.pos++
+	n, err := strconv.ParseInt(tok.text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s at position %d", tok.text, tok.pos)
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	tok := p.peek()
+	found := tok.text
+	if tok.kind == tokEOF {
+		found = "end of query"
+	}
+	return fmt.Errorf("syntax error at position %d near %q: %s", tok.pos, found, fmt.Sprintf(format, args...))
+}
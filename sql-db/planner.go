@@ -0,0 +1,338 @@
+package sqldb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Costs are in units of one version checked by a full scan.
+const (
+	seqRowCost       = 1.0 // checking one version during a full scan
+	indexRowCost     = 2.0 // fetching one version found through an index
+	indexLookupCost  = 4.0 // one probe of an index
+	hashRowCost      = 1.5 // building or probing a hash table with one row
+	rangeSelectivity = 1.0 / 3
+	likeSelectivity  = 1.0 / 10
+)
+
+// PlanNode is one step of a query plan, as returned by Explain. Steps consume the
+// rows of their children.
+type PlanNode struct {
+	Op        string  // e.g. "Seq Scan", "Index Scan", "Hash Join", "Filter", "Aggregate", "Sort", "Limit"
+	Detail    string  // table, index, condition or keys of the step
+	Cost      float64 // estimated cost of the step including its children
+	Estimated float64 // estimated number of rows produced
+	Actual    int     // number of rows produced when the query ran
+	Children  []*PlanNode
+}
+
+// String renders the plan as an indented tree, one step per line.
+func (n *PlanNode) String() string {
+	var sb strings.Builder
+	n.write(&sb, "")
+	return sb.String()
+}
+
+func (n *PlanNode) write(sb *strings.Builder, indent string) {
+	sb.WriteString(indent)
+	if indent != "" {
+		sb.WriteString("-> ")
+	}
+	sb.WriteString(n.Op)
+	if n.Detail != "" {
+		sb.WriteString(" " + n.Detail)
+	}
+	fmt.Fprintf(sb, "  (cost=%.1f rows=%.0f actual=%d)\n", n.Cost, n.Estimated, n.Actual)
+	for _, child := range n.Children {
+		child.write(sb, indent+"   ")
+	}
+}
+
+// tableStats summarises the current rows of a table for the planner. Stats are
+// gathered on demand and again once the table's versions grew or shrank by a tenth.
+type tableStats struct {
+	versions int // row versions, current or not, when gathered
+	rows     int // current rows
+	distinct map[string]int
+	nulls    map[string]int
+}
+
+func (t *Table) statistics() *tableStats {
+	versions := *t.versions.Load()
+	if st := t.stats.Load(); st != nil {
+		if diff := len(versions) - st.versions; diff <= st.versions/10 && -diff <= st.versions/10 {
+			return st
+		}
+	}
+
+	st := &tableStats{versions: len(versions), distinct: make(map[string]int), nulls: make(map[string]int)}
+	values := make(map[string]map[any]bool, len(t.Columns))
+	for _, col := range t.Columns {
+		values[col.Name] = make(map[any]bool)
+	}
+	for _, v := range versions {
+		if v.xmax.Load() != 0 {
+			continue
+		}
+		st.rows++
+		for _, col := range t.Columns {
+			if val := v.data[col.Name]; val != nil {
+				values[col.Name][normalizeKey(val)] = true
+			} else {
+				st.nulls[col.Name]++
+			}
+		}
+	}
+	for col, seen := range values {
+		st.distinct[col] = len(seen)
+	}
+	t.stats.Store(st)
+	return st
+}
+
+// columnStats is what the planner knows about one column.
+type columnStats struct {
+	rows     int
+	distinct int
+	nulls    int
+}
+
+// estimator maps the column names a predicate may use to their stats.
+type estimator map[string]columnStats
+
+// addTable adds the columns of t, prefixed with prefix.
+func (e estimator) addTable(t *Table, prefix string) {
+	st := t.statistics()
+	for _, col := range t.Columns {
+		e[prefix+col.Name] = columnStats{rows: st.rows, distinct: st.distinct[col.Name], nulls: st.nulls[col.Name]}
+	}
+}
+
+// queryEstimator has the stats of the columns a query reads, under the names the
+// query uses for them.
+func queryEstimator(from source, joins []source) estimator {
+	e := make(estimator)
+	if len(joins) == 0 {
+		e.addTable(from.table, "")
+		return e
+	}
+	for _, src := range append([]source{from}, joins...) {
+		e.addTable(src.table, src.table.Name+".")
+	}
+	return e
+}
+
+// eqSelectivity is the fraction of rows holding any one non-NULL value of column.
+func (e estimator) eqSelectivity(column string) float64 {
+	cs, ok := e[column]
+	if !ok || cs.rows == 0 {
+		return likeSelectivity
+	}
+	return e.notNull(column) / float64(max(cs.distinct, 1))
+}
+
+func (e estimator) notNull(column string) float64 {
+	cs, ok := e[column]
+	if !ok || cs.rows == 0 {
+		return 1
+	}
+	return 1 - float64(cs.nulls)/float64(cs.rows)
+}
+
+// selectivity estimates the fraction of rows matching pred, assuming independent
+// columns and uniformly distributed values.
+func (e estimator) selectivity(pred Predicate) float64 {
+	switch p := pred.(type) {
+	case nil:
+		return 1
+	case *Comparison:
+		switch p.Op {
+		case OpEq:
+			return e.eqSelectivity(p.Column)
+		case OpNe:
+			return e.notNull(p.Column) - e.eqSelectivity(p.Column)
+		}
+		return e.notNull(p.Column) * rangeSelectivity
+	case *InList:
+		return math.Min(e.notNull(p.Column), float64(len(p.Values))*e.eqSelectivity(p.Column))
+	case *Like:
+		return e.notNull(p.Column) * likeSelectivity
+	case *IsNull:
+		return 1 - e.notNull(p.Column)
+	case AndPredicate:
+		sel := 1.0
+		for _, child := range p {
+			sel *= e.selectivity(child)
+		}
+		return sel
+	case OrPredicate:
+		miss := 1.0
+		for _, child := range p {
+			miss *= 1 - e.selectivity(child)
+		}
+		return 1 - miss
+	case *NotPredicate:
+		return 1 - e.selectivity(p.Inner)
+	}
+	return rangeSelectivity
+}
+
+// accessPath is how a scan finds its candidate versions: a lookup of one index, or
+// the union of lookups for the branches of an OR. A nil path is a full scan.
+type accessPath struct {
+	index *Index
+	pred  Predicate     // the comparison or IN list the index lookup serves
+	union []*accessPath // lookups whose results are combined
+	rows  float64       // estimated versions fetched
+	cost  float64
+}
+
+// planAccess picks the cheapest way to find the versions that may match pred. The
+// caller must hold t.idxMu.
+func (t *Table) planAccess(pred Predicate) *accessPath {
+	if pred == nil || !t.hasIndexFor(pred) {
+		return nil
+	}
+	st := t.statistics()
+	e := make(estimator)
+	e.addTable(t, "")
+	path := t.indexPath(pred, st, e)
+	if path == nil || path.cost >= float64(st.versions)*seqRowCost {
+		return nil
+	}
+	return path
+}
+
+func (t *Table) hasIndexFor(pred Predicate) bool {
+	for _, col := range predicateColumns(pred) {
+		if t.indexes[col] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// indexPath returns the cheapest index lookup serving pred, or nil if no index
+// applies. The caller must hold t.idxMu.
+func (t *Table) indexPath(pred Predicate, st *tableStats, e estimator) *accessPath {
+	versions := float64(st.versions)
+	switch p := pred.(type) {
+	case *Comparison:
+		idx := t.indexes[p.Column]
+		if idx == nil || p.Op == OpNe {
+			return nil
+		}
+		path := &accessPath{index: idx, pred: p, cost: indexLookupCost}
+		switch {
+		case !idx.accepts(p.Value):
+			// the lookup is known to be empty
+		case p.Op == OpEq:
+			path.rows = versions * e.eqSelectivity(p.Column)
+		case idx.Kind != OrderedIndex:
+			return nil
+		default:
+			path.rows = versions * e.selectivity(p)
+		}
+		path.cost += path.rows * indexRowCost
+		return path
+	case *InList:
+		idx := t.indexes[p.Column]
+		if idx == nil {
+			return nil
+		}
+		rows := versions * e.selectivity(p)
+		return &accessPath{index: idx, pred: p, rows: rows, cost: float64(len(p.Values))*indexLookupCost + rows*indexRowCost}
+	case AndPredicate:
+		// the cheapest indexed conjunct drives the lookup, the others filter
+		var best *accessPath
+		for _, child := range p {
+			if path := t.indexPath(child, st, e); path != nil && (best == nil || path.cost < best.cost) {
+				best = path
+			}
+		}
+		return best
+	case OrPredicate:
+		// every branch has to be indexed, otherwise a full scan is needed anyway
+		union := &accessPath{}
+		for _, child := range p {
+			path := t.indexPath(child, st, e)
+			if path == nil {
+				return nil
+			}
+			union.union = append(union.union, path)
+			union.rows += path.rows
+			union.cost += path.cost
+		}
+		return union
+	}
+	return nil
+}
+
+// fetch returns the versions path finds. The caller must hold t.idxMu.
+func (t *Table) fetch(path *accessPath) []*rowVersion {
+	if path.index == nil {
+		var versions []*rowVersion
+		for _, child := range path.union {
+			versions = append(versions, t.fetch(child)...)
+		}
+		return uniqueVersions(versions)
+	}
+	idx := path.index
+	switch p := path.pred.(type) {
+	case *Comparison:
+		switch {
+		case !idx.accepts(p.Value):
+			return nil
+		case p.Op == OpEq:
+			return append([]*rowVersion(nil), idx.lookup(p.Value)...)
+		case p.Op == OpLt, p.Op == OpLe:
+			return idx.scanRange(nil, false, p.Value, p.Op == OpLe)
+		case p.Op == OpGt, p.Op == OpGe:
+			return idx.scanRange(p.Value, p.Op == OpGe, nil, false)
+		}
+	case *InList:
+		var versions []*rowVersion
+		for _, val := range p.Values {
+			versions = append(versions, idx.lookup(val)...)
+		}
+		return uniqueVersions(versions)
+	}
+	return nil
+}
+
+// describe names the indexes and conditions of the lookup.
+func (path *accessPath) describe() string {
+	if path.index != nil {
+		return fmt.Sprintf("%s index on %s (%v)", path.index.Kind, path.index.Column, path.pred)
+	}
+	parts := make([]string, len(path.union))
+	for i, child := range path.union {
+		parts[i] = child.describe()
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// scanNode describes a scan of t for pred that went through path and produced
+// actual rows.
+func (t *Table) scanNode(pred Predicate, path *accessPath, actual int) *PlanNode {
+	st := t.statistics()
+	e := make(estimator)
+	e.addTable(t, "")
+	node := &PlanNode{
+		Op:        "Seq Scan",
+		Detail:    "on " + t.Name,
+		Cost:      float64(st.versions) * seqRowCost,
+		Estimated: float64(st.rows) * e.selectivity(pred),
+		Actual:    actual,
+	}
+	if path != nil {
+		node.Op = "Index Scan"
+		node.Detail += " using " + path.describe()
+		node.Cost = path.cost
+	}
+	if pred != nil && (path == nil || path.pred != pred) {
+		node.Detail += fmt.Sprintf(" filter: %v", pred)
+	}
+	return node
+}
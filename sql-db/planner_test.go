@@ -0,0 +1,109 @@
+package sqldb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planSteps lists the steps of a plan depth first, as "Op actual".
+func planSteps(plan *PlanNode) []string {
+	steps := []string{fmt.Sprintf("%s %d", plan.Op, plan.Actual)}
+	for _, child := range plan.Children {
+		steps = append(steps, planSteps(child)...)
+	}
+	return steps
+}
+
+func TestExplainPlans(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, `
+		CREATE TABLE users (id INT PRIMARY KEY, name STRING, age INT);
+		CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, total INT);
+	`)
+	for i := range 500 {
+		mustExec(t, db, "INSERT INTO users (id, name, age) VALUES (?, ?, ?)", i, fmt.Sprint("u", i), i%50)
+		mustExec(t, db, "INSERT INTO orders (id, user_id, total) VALUES (?, ?, ?)", i, i%100, i)
+	}
+	if err := db.CreateIndex("users", "age", OrderedIndex); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query  string
+		steps  []string
+		detail string // part of the top step's detail
+	}{
+		{"SELECT * FROM users WHERE id = 5", []string{"Index Scan 1"}, "hash index on id"},
+		{"SELECT * FROM users WHERE name = 'u1'", []string{"Seq Scan 1"}, "filter: name = 'u1'"},
+		{"SELECT * FROM users WHERE id = 1 OR age = 3", []string{"Index Scan 11"}, "hash index on id (id = 1) OR ordered index on age (age = 3)"},
+		{"SELECT * FROM users WHERE age > 45 ORDER BY name LIMIT 3", []string{"Limit 3", "Sort 40", "Index Scan 40"}, "3"},
+		{"SELECT age, COUNT(*) FROM users GROUP BY age", []string{"Aggregate 50", "Seq Scan 500"}, "by age"},
+		{
+			"SELECT users.name, orders.total FROM users JOIN orders ON orders.user_id = users.id WHERE users.id = 3",
+			[]string{"Hash Join 5", "Index Scan 1", "Seq Scan 500"},
+			"users.id = orders.user_id",
+		},
+	}
+	for _, tt := range tests {
+		plan, err := db.Explain(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := planSteps(plan); !reflect.DeepEqual(got, tt.steps) || !strings.Contains(plan.Detail, tt.detail) {
+			t.Errorf("%s: plan\n%vwant steps %v with %q", tt.query, plan, tt.steps, tt.detail)
+		}
+		if plan.Cost <= 0 {
+			t.Errorf("%s: plan has no cost", tt.query)
+		}
+	}
+}
+
+func TestIndexAndSeqScanAgree(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, "CREATE TABLE users (id INT PRIMARY KEY, name STRING, age INT)")
+	for i := range 500 {
+		mustExec(t, db, "INSERT INTO users (id, name, age) VALUES (?, ?, ?)", i, fmt.Sprint("u", i), i%50)
+	}
+	if err := db.CreateIndex("users", "age", OrderedIndex); err != nil {
+		t.Fatal(err)
+	}
+	queries := []string{
+		"SELECT id FROM users WHERE age = 7",
+		"SELECT id FROM users WHERE age >= 48",
+		"SELECT id FROM users WHERE age < 2 AND id > 400",
+		"SELECT id FROM users WHERE age IN (1, 2) OR id = 3",
+	}
+	indexed := make([]*ResultSet, len(queries))
+	for i, query := range queries {
+		indexed[i] = mustQuery(t, db, query)
+	}
+	if err := db.DropIndex("users", "age"); err != nil {
+		t.Fatal(err)
+	}
+	for i, query := range queries {
+		scanned := mustQuery(t, db, query)
+		if len(scanned.Rows) == 0 || !reflect.DeepEqual(indexed[i].Rows, scanned.Rows) {
+			t.Errorf("%s: index found %d rows, scan %d", query, len(indexed[i].Rows), len(scanned.Rows))
+		}
+	}
+}
+
+func TestExplainStatement(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, "CREATE TABLE users (id INT PRIMARY KEY, name STRING, age INT)")
+	for i := range 500 {
+		mustExec(t, db, "INSERT INTO users (id, name, age) VALUES (?, ?, ?)", i, fmt.Sprint("u", i), i%50)
+	}
+	if err := db.CreateIndex("users", "age", OrderedIndex); err != nil {
+		t.Fatal(err)
+	}
+	rs := mustQuery(t, db, "EXPLAIN SELECT * FROM users WHERE age > 45 ORDER BY name LIMIT 3")
+	if len(rs.Rows) != 3 || !strings.HasPrefix(rs.Rows[0]["plan"].(string), "Limit 3") ||
+		!strings.HasPrefix(rs.Rows[2]["plan"].(string), "      -> Index Scan on users") {
+		t.Fatalf("EXPLAIN returned %v, want one row per plan step", rs.Rows)
+	}
+	if _, err := db.Explain("DELETE FROM users"); err == nil {
+		t.Fatal("explaining a DELETE succeeded")
+	}
+}
@@ -0,0 +1,448 @@
+package sqldb
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Predicate decides whether a row matches a WHERE clause.
+type Predicate interface {
+	Match(row map[string]any) bool
+}
+
+type CompareOp string
+
+const (
+	OpEq CompareOp = "="
+	OpNe CompareOp = "!="
+	OpLt CompareOp = "<"
+	OpLe CompareOp = "<="
+	OpGt CompareOp = ">"
+	OpGe CompareOp = ">="
+)
+
+// Comparison compares a column against a constant. Comparisons involving NULL never
+// match, and neither do their negations.
+type Comparison struct {
+	Column string
+	Op     CompareOp
+	Value  any
+}
+
+type InList struct {
+	Column string
+	Values []any
+}
+
+// Like matches string columns against a pattern where % matches any run of
+// characters and _ matches a single character.
+type Like struct {
+	Column  string
+	Pattern string
+}
+
+type IsNull struct {
+	Column string
+}
+
+type AndPredicate []Predicate
+type OrPredicate []Predicate
+
+type NotPredicate struct {
+	Inner Predicate
+}
+
+func Eq(col string, val any) Predicate { return &Comparison{Column: col, Op: OpEq, Value: val} }
+func Ne(col string, val any) Predicate { return &Comparison{Column: col, Op: OpNe, Value: val} }
+func Lt(col string, val any) Predicate { return &Comparison{Column: col, Op: OpLt, Value: val} }
+func Le(col string, val any) Predicate { return &Comparison{Column: col, Op: OpLe, Value: val} }
+func Gt(col string, val any) Predicate { return &Comparison{Column: col, Op: OpGt, Value: val} }
+func Ge(col string, val any) Predicate { return &Comparison{Column: col, Op: OpGe, Value: val} }
+
+func In(col string, vals ...any) Predicate      { return &InList{Column: col, Values: vals} }
+func LikePattern(col, pattern string) Predicate { return &Like{Column: col, Pattern: pattern} }
+func HasPrefix(col, prefix string) Predicate {
+	return &Like{Column: col, Pattern: escapeLike(prefix) + "%"}
+}
+func Null(col string) Predicate    { return &IsNull{Column: col} }
+func NotNull(col string) Predicate { return Not(Null(col)) }
+
+func And(preds ...Predicate) Predicate { return AndPredicate(preds) }
+func Or(preds ...Predicate) Predicate  { return OrPredicate(preds) }
+func Not(pred Predicate) Predicate     { return &NotPredicate{Inner: pred} }
+
+// FilterFromMap converts an equality filter, as accepted by GetRecords, into a Predicate.
+// A nil value in the filter matches rows where the column is NULL.
+func FilterFromMap(filter map[string]any) Predicate {
+	if len(filter) == 0 {
+		return nil
+	}
+	var preds AndPredicate
+	for col, val := range filter {
+		if val == nil {
+			preds = append(preds, Null(col))
+			continue
+		}
+		preds = append(preds, Eq(col, val))
+	}
+	return preds
+}
+
+// truth is the result of SQL's three-valued logic: a predicate on a NULL is
+// unknown rather than false, and so is its negation. Only true matches.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthUnknown
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// evaluate applies pred to row. Predicates defined outside this package are
+// never unknown.
+func evaluate(pred Predicate, row map[string]any) truth {
+	switch p := pred.(type) {
+	case *Comparison:
+		return p.evaluate(row)
+	case *InList:
+		return p.evaluate(row)
+	case *Like:
+		return p.evaluate(row)
+	case AndPredicate:
+		result := truthTrue
+		for _, pred := range p {
+			if result = min(result, evaluate(pred, row)); result == truthFalse {
+				break
+			}
+		}
+		return result
+	case OrPredicate:
+		result := truthFalse
+		for _, pred := range p {
+			if result = max(result, evaluate(pred, row)); result == truthTrue {
+				break
+			}
+		}
+		return result
+	case *NotPredicate:
+		return truthTrue - evaluate(p.Inner, row)
+	}
+	return truthOf(pred.Match(row))
+}
+
+func (c *Comparison) Match(row map[string]any) bool {
+	return c.evaluate(row) == truthTrue
+}
+
+func (c *Comparison) evaluate(row map[string]any) truth {
+	cmp, ok := compareValues(row[c.Column], c.Value)
+	if !ok {
+		return truthUnknown
+	}
+	switch c.Op {
+	case OpEq:
+		return truthOf(cmp == 0)
+	case OpNe:
+		return truthOf(cmp != 0)
+	case OpLt:
+		return truthOf(cmp < 0)
+	case OpLe:
+		return truthOf(cmp <= 0)
+	case OpGt:
+		return truthOf(cmp > 0)
+	case OpGe:
+		return truthOf(cmp >= 0)
+	}
+	return truthFalse
+}
+
+func (in *InList) Match(row map[string]any) bool {
+	return in.evaluate(row) == truthTrue
+}
+
+// evaluate is unknown for a NULL column, and for a value that matches none of
+// the list when the list holds a NULL.
+func (in *InList) evaluate(row map[string]any) truth {
+	result := truthFalse
+	for _, val := range in.Values {
+		cmp, ok := compareValues(row[in.Column], val)
+		if !ok {
+			result = truthUnknown
+		} else if cmp == 0 {
+			return truthTrue
+		}
+	}
+	return result
+}
+
+func (l *Like) Match(row map[string]any) bool {
+	return l.evaluate(row) == truthTrue
+}
+
+func (l *Like) evaluate(row map[string]any) truth {
+	str, ok := row[l.Column].(string)
+	if !ok {
+		return truthUnknown
+	}
+	return truthOf(matchLike(str, l.Pattern))
+}
+
+func (n *IsNull) Match(row map[string]any) bool {
+	return row[n.Column] == nil
+}
+
+func (a AndPredicate) Match(row map[string]any) bool {
+	return evaluate(a, row) == truthTrue
+}
+
+func (o OrPredicate) Match(row map[string]any) bool {
+	return evaluate(o, row) == truthTrue
+}
+
+// Match follows SQL: NOT of a predicate on a NULL doesn't match either.
+func (n *NotPredicate) Match(row map[string]any) bool {
+	return evaluate(n, row) == truthTrue
+}
+
+// predicateColumns lists every column referenced by pred.
+func predicateColumns(pred Predicate) []string {
+	switch p := pred.(type) {
+	case *Comparison:
+		return []string{p.Column}
+	case *InList:
+		return []string{p.Column}
+	case *Like:
+		return []string{p.Column}
+	case *IsNull:
+		return []string{p.Column}
+	case AndPredicate:
+		return childColumns(p)
+	case OrPredicate:
+		return childColumns(p)
+	case *NotPredicate:
+		return predicateColumns(p.Inner)
+	}
+	return nil
+}
+
+func childColumns(preds []Predicate) []string {
+	var cols []string
+	for _, pred := range preds {
+		cols = append(cols, predicateColumns(pred)...)
+	}
+	return cols
+}
+
+// compareValues orders two non-NULL values of compatible types. ok is false
+// when either value is NULL or the types can not be compared.
+func compareValues(a, b any) (order int, ok bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if aInt, err := convertToInt(a); err == nil {
+		if bInt, err := convertToInt(b); err == nil {
+			return cmp.Compare(aInt, bInt), true
+		}
+	}
+	if aNum, err := convertToFloat(a); err == nil {
+		bNum, err := convertToFloat(b)
+		if err != nil {
+			return 0, false
+		}
+		return cmp.Compare(aNum, bNum), true
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bTime, isTime := b.(time.Time); isTime {
+			aTime, err := convertToTime(av)
+			if err != nil {
+				return 0, false
+			}
+			return aTime.Compare(bTime), true
+		}
+		bStr, isStr := b.(string)
+		if !isStr {
+			return 0, false
+		}
+		return strings.Compare(av, bStr), true
+	case bool:
+		bBool, isBool := b.(bool)
+		if !isBool {
+			return 0, false
+		}
+		switch {
+		case av == bBool:
+			return 0, true
+		case bBool:
+			return -1, true
+		}
+		return 1, true
+	case time.Time:
+		bTime, err := convertToTime(b)
+		if err != nil {
+			return 0, false
+		}
+		return av.Compare(bTime), true
+	case []byte:
+		bBytes, err := convertToBytes(b)
+		if err != nil {
+			return 0, false
+		}
+		return bytes.Compare(av, bBytes), true
+	case json.RawMessage:
+		bJSON, err := convertToJSON(b)
+		if err != nil {
+			return 0, false
+		}
+		return bytes.Compare(av, bJSON), true
+	}
+	return 0, false
+}
+
+// matchLike reports whether s matches a LIKE pattern.
+func matchLike(s, pattern string) bool {
+	sr, pr := []rune(s), []rune(pattern)
+	// backtracking match, remembering the position of the last %
+	si, pi := 0, 0
+	starP, starS := -1, 0
+	for si < len(sr) {
+		switch {
+		case pi < len(pr) && pr[pi] == '\\' && pi+1 < len(pr) && pr[pi+1] == sr[si]:
+			si++
+			pi += 2
+		case pi < len(pr) && (pr[pi] == '_' || (pr[pi] == sr[si] && pr[pi] != '%' && pr[pi] != '\\')):
+			si++
+			pi++
+		case pi < len(pr) && pr[pi] == '%':
+			starP, starS = pi, si
+			pi++
+		case starP >= 0:
+			starS++
+			si = starS
+			pi = starP + 1
+		default:
+			return false
+		}
+	}
+	for pi < len(pr) && pr[pi] == '%' {
+		pi++
+	}
+	return pi == len(pr)
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+func (c *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", c.Column, c.Op, formatLiteral(c.Value))
+}
+
+func (in *InList) String() string {
+	values := make([]string, len(in.Values))
+	for i, val := range in.Values {
+		values[i] = formatLiteral(val)
+	}
+	return fmt.Sprintf("%s IN (%s)", in.Column, strings.Join(values, ", "))
+}
+
+func (l *Like) String() string {
+	return fmt.Sprintf("%s LIKE %s", l.Column, formatLiteral(l.Pattern))
+}
+
+func (n *IsNull) String() string {
+	return n.Column + " IS NULL"
+}
+
+func (a AndPredicate) String() string {
+	return joinPredicates(a, " AND ")
+}
+
+func (o OrPredicate) String() string {
+	return joinPredicates(o, " OR ")
+}
+
+func (n *NotPredicate) String() string {
+	if isNull, ok := n.Inner.(*IsNull); ok {
+		return isNull.Column + " IS NOT NULL"
+	}
+	return "NOT (" + fmt.Sprint(n.Inner) + ")"
+}
+
+func joinPredicates(preds []Predicate, sep string) string {
+	parts := make([]string, len(preds))
+	for i, pred := range preds {
+		parts[i] = fmt.Sprint(pred)
+		switch pred.(type) {
+		case AndPredicate, OrPredicate:
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// formatLiteral writes a value the way the SQL parser reads it.
+func formatLiteral(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'"
+	case []byte:
+		return fmt.Sprintf("x'%x'", v)
+	case json.RawMessage:
+		return "'" + strings.ReplaceAll(string(v), "'", "''") + "'"
+	}
+	return fmt.Sprint(val)
+}
+
+// renameColumns returns a copy of pred referring to column rename(c) wherever pred
+// refers to c. ok is false for predicate types it doesn't know.
+func renameColumns(pred Predicate, rename func(string) string) (renamed Predicate, ok bool) {
+	switch p := pred.(type) {
+	case *Comparison:
+		return &Comparison{Column: rename(p.Column), Op: p.Op, Value: p.Value}, true
+	case *InList:
+		return &InList{Column: rename(p.Column), Values: p.Values}, true
+	case *Like:
+		return &Like{Column: rename(p.Column), Pattern: p.Pattern}, true
+	case *IsNull:
+		return &IsNull{Column: rename(p.Column)}, true
+	case AndPredicate:
+		children, ok := renameChildren(p, rename)
+		return AndPredicate(children), ok
+	case OrPredicate:
+		children, ok := renameChildren(p, rename)
+		return OrPredicate(children), ok
+	case *NotPredicate:
+		inner, ok := renameColumns(p.Inner, rename)
+		return &NotPredicate{Inner: inner}, ok
+	}
+	return nil, false
+}
+
+func renameChildren(preds []Predicate, rename func(string) string) ([]Predicate, bool) {
+	renamed := make([]Predicate, len(preds))
+	for i, pred := range preds {
+		var ok bool
+		if renamed[i], ok = renameColumns(pred, rename); !ok {
+			return nil, false
+		}
+	}
+	return renamed, true
+}
@@ -0,0 +1,97 @@
+package sqldb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPredicateMatch(t *testing.T) {
+	row := map[string]any{"id": int64(2048), "username": "admin_1", "email": nil}
+	tests := []struct {
+		pred Predicate
+		want bool
+	}{
+		{Ge("id", 2000), true},
+		{Gt("id", 2048), false},
+		{Le("id", 2048.0), true},
+		{Lt("id", "abc"), false},
+		{Ne("id", 1), true},
+		{In("id", 1, 2048), true},
+		{In("id", 1, 2), false},
+		{LikePattern("username", "adm%"), true},
+		{LikePattern("username", "admin__"), true},
+		{LikePattern("username", "admin_"), false},
+		{LikePattern("username", `admin\_%`), true},
+		{HasPrefix("username", "adm"), true},
+		{HasPrefix("username", "ad%"), false},
+		{Null("email"), true},
+		{NotNull("username"), true},
+		{Eq("email", nil), false},
+		{And(Ge("id", 2000), LikePattern("username", "adm%")), true},
+		{And(Ge("id", 2000), Null("username")), false},
+		{Or(Lt("id", 10), HasPrefix("username", "adm")), true},
+		{Not(Or(Lt("id", 10), Null("email"))), false},
+		{Not(Gt("email", 1)), false},
+		{Not(In("email", "a")), false},
+		{Not(LikePattern("email", "%")), false},
+		{In("id", nil, 2048), true},
+		{Not(In("id", 1, nil)), false},
+		{Not(Not(Eq("email", "a"))), false},
+		{Or(Eq("email", "a"), Not(Eq("email", "a"))), false},
+		{Not(And(Eq("email", "a"), Lt("id", 10))), true},
+		{And(), true},
+		{Or(), false},
+	}
+	for _, tt := range tests {
+		if got := tt.pred.Match(row); got != tt.want {
+			t.Errorf("%v matches %v = %v, want %v", tt.pred, row, got, tt.want)
+		}
+	}
+}
+
+func TestPredicateString(t *testing.T) {
+	pred := And(Ge("id", 2000), Or(LikePattern("username", "adm%"), In("id", 1, 2)), NotNull("email"))
+	want := "id >= 2000 AND (username LIKE 'adm%' OR id IN (1, 2)) AND email IS NOT NULL"
+	if got := fmt.Sprint(pred); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestGetRecordsWhere(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", []*Column{NewColumn("id", TypeInt), NewColumn("name", TypeString)},
+		map[string]any{"id": 1, "name": "ada"},
+		map[string]any{"id": 2000, "name": "root"},
+		map[string]any{"id": 2001, "name": "admin"},
+		map[string]any{"id": 2002, "name": "adamo"},
+	)
+	rows, err := db.GetRecordsWhere("users", And(Ge("id", 2000), LikePattern("name", "ad%")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnValues(rows, "name"); !reflect.DeepEqual(got, []any{"admin", "adamo"}) {
+		t.Fatalf("got %v, want admin and adamo", got)
+	}
+	if _, err := db.GetRecordsWhere("users", Eq("nope", 1)); err == nil {
+		t.Fatal("predicate on an unknown column succeeded")
+	}
+}
+
+func TestNotSkipsNulls(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, `
+		CREATE TABLE people (name STRING, age INT);
+		INSERT INTO people (name, age) VALUES ('ada', 36), ('bob', 40), (NULL, NULL);
+	`)
+	for _, query := range []string{
+		"SELECT name FROM people WHERE NOT (age > 38)",
+		"SELECT name FROM people WHERE age NOT IN (40)",
+		"SELECT name FROM people WHERE name NOT LIKE 'b%'",
+	} {
+		rs := mustQuery(t, db, query)
+		if got := columnValues(rs.Rows, "name"); !reflect.DeepEqual(got, []any{"ada"}) {
+			t.Errorf("%s = %v, want ada only", query, got)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package sqldb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// QueryOptions describes a read of one table.
+type QueryOptions struct {
+	Columns []string // projection, empty means every column
+	Where   Predicate
+	OrderBy []OrderBy // rows that tie on every key stay in insertion order
+	Limit   *int      // nil means no limit; see Limit
+	Offset  int
+
+	// GroupBy and Aggregates turn the read into an aggregate query returning one row per
+	// group, with the GROUP BY columns and one column per aggregate. Columns, OrderBy
+	// and Having refer to those result columns.
+	GroupBy    []string
+	Aggregates []Aggregate
+	Having     Predicate
+
+	// Joins combine the rows of other tables with the rows read; every column name
+	// used with joins is qualified with its table, e.g. "users.id".
+	Joins []Join
+
+	// After resumes a paginated read after the last row of a previous page; it takes
+	// the Cursor of that page's ResultSet and must be used with the same OrderBy.
+	After string
+}
+
+type OrderBy struct {
+	Column string
+	Desc   bool
+}
+
+// Limit returns a QueryOptions.Limit of n rows.
+func Limit(n int) *int {
+	return &n
+}
+
+func Asc(column string) OrderBy {
+	return OrderBy{Column: column}
+}
+
+func Desc(column string) OrderBy {
+	return OrderBy{Column: column, Desc: true}
+}
+
+// Select runs a read described by opts. The rows returned are copies, so callers
+// are free to modify them. Joins are resolved through the table's database.
+func (t *Table) Select(opts QueryOptions) (*ResultSet, error) {
+	if len(opts.Joins) > 0 {
+		if t.db == nil {
+			return nil, fmt.Errorf("table %s is not part of a database to join with", t.Name)
+		}
+		return t.db.Select(t.Name, opts)
+	}
+	var rs *ResultSet
+	var err error
+	t.read(func(snapshot uint64) {
+		rs, _, err = runQuery(snapshot, source{table: t}, nil, opts, false)
+	})
+	return rs, err
+}
+
+// runQuery reads from, joined with joins, as seen by snapshot. With explain set it
+// also returns the plan it followed, with the rows each step produced.
+func runQuery(snapshot uint64, from source, joins []source, opts QueryOptions, explain bool) (*ResultSet, *PlanNode, error) {
+	columns := from.table.Columns
+	if len(joins) > 0 {
+		var err error
+		if columns, err = joinColumns(from, joins, opts.Joins); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := checkPredicateColumns(columns, opts.Where); err != nil {
+		return nil, nil, err
+	}
+	if (opts.Limit != nil && *opts.Limit < 0) || opts.Offset < 0 {
+		return nil, nil, fmt.Errorf("limit and offset can not be negative")
+	}
+	grouped := len(opts.GroupBy) > 0 || len(opts.Aggregates) > 0
+	// a cursor holds a row id, which only a plain read of one table has
+	paginated := !grouped && len(joins) == 0
+	if opts.After != "" && !paginated {
+		return nil, nil, fmt.Errorf("cursors are not supported for aggregate or join queries")
+	}
+	var available []string
+	if grouped {
+		var err error
+		if available, err = checkAggregates(columns, opts); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if opts.Having != nil {
+			return nil, nil, fmt.Errorf("HAVING needs GROUP BY or aggregates")
+		}
+		for _, col := range columns {
+			available = append(available, col.Name)
+		}
+	}
+	resultColumns := opts.Columns
+	if len(resultColumns) == 0 {
+		resultColumns = available
+	}
+	for _, name := range resultColumns {
+		if !slices.Contains(available, name) {
+			return nil, nil, unknownColumn(name)
+		}
+	}
+	for _, order := range opts.OrderBy {
+		if !slices.Contains(available, order.Column) {
+			return nil, nil, unknownColumn(order.Column)
+		}
+	}
+
+	// rows come ordered by id, so a stable sort leaves ties in insertion order
+	var refs []rowRef
+	var plan *PlanNode
+	if len(joins) == 0 {
+		var path *accessPath
+		refs, path = from.table.scanPath(snapshot, opts.Where, from.ws)
+		if explain {
+			plan = from.table.scanNode(opts.Where, path, len(refs))
+		}
+	} else {
+		refs, plan = planJoins(from, joins, opts).run(snapshot, explain)
+	}
+	if grouped {
+		input := len(refs)
+		var err error
+		if refs, err = aggregate(columns, refs, opts); err != nil {
+			return nil, nil, err
+		}
+		if explain {
+			plan = aggregateNode(plan, queryEstimator(from, joins), opts, input, len(refs))
+		}
+	}
+	if len(opts.OrderBy) > 0 {
+		sort.SliceStable(refs, func(i, j int) bool {
+			return compareRefs(refs[i], refs[j], opts.OrderBy) < 0
+		})
+		if explain {
+			keys := make([]string, len(opts.OrderBy))
+			for i, order := range opts.OrderBy {
+				keys[i] = order.Column
+				if order.Desc {
+					keys[i] += " DESC"
+				}
+			}
+			n := math.Max(plan.Estimated, 1)
+			plan = &PlanNode{Op: "Sort", Detail: "by " + strings.Join(keys, ", "), Cost: plan.Cost + n*math.Log2(n+1),
+				Estimated: plan.Estimated, Actual: len(refs), Children: []*PlanNode{plan}}
+		}
+	}
+	input := len(refs)
+	if opts.After != "" {
+		after, err := decodeCursor(columns, opts.After, opts.OrderBy)
+		if err != nil {
+			return nil, nil, err
+		}
+		start := sort.Search(len(refs), func(i int) bool {
+			return compareRefs(refs[i], after, opts.OrderBy) > 0
+		})
+		refs = refs[start:]
+	}
+	refs = refs[min(opts.Offset, len(refs)):]
+
+	rs := &ResultSet{Columns: resultColumns}
+	if opts.Limit != nil && len(refs) > *opts.Limit {
+		refs = refs[:*opts.Limit]
+		if paginated && len(refs) > 0 {
+			cursor, err := encodeCursor(refs[len(refs)-1], opts.OrderBy)
+			if err != nil {
+				return nil, nil, err
+			}
+			rs.Cursor = cursor
+		}
+	}
+	if explain && len(refs) != input {
+		estimated := math.Max(plan.Estimated-float64(opts.Offset), 0)
+		detail := fmt.Sprintf("offset %d", opts.Offset)
+		if opts.Limit != nil {
+			estimated = math.Min(estimated, float64(*opts.Limit))
+			detail = fmt.Sprintf("%d %s", *opts.Limit, detail)
+		}
+		plan = &PlanNode{Op: "Limit", Detail: detail, Cost: plan.Cost,
+			Estimated: estimated, Actual: len(refs), Children: []*PlanNode{plan}}
+	}
+	for _, ref := range refs {
+		row := make(map[string]any, len(resultColumns))
+		for _, col := range resultColumns {
+			if val, ok := ref.data[col]; ok {
+				row[col] = copyValue(val)
+			}
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+	return rs, plan, nil
+}
+
+// compareRefs orders rows by the given keys, then by id. NULLs sort first.
+func compareRefs(a, b rowRef, orderBy []OrderBy) int {
+	for _, order := range orderBy {
+		c := compareKeys(a.data[order.Column], b.data[order.Column])
+		if order.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a.id < b.id:
+		return -1
+	case a.id > b.id:
+		return 1
+	}
+	return 0
+}
+
+func compareKeys(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compareValues(a, b)
+	return c
+}
+
+// cursor is the position of the last row of a page: its sort keys and id.
+type cursor struct {
+	Keys map[string]json.RawMessage `json:"keys,omitempty"`
+	ID   int64                      `json:"id"`
+}
+
+func encodeCursor(last rowRef, orderBy []OrderBy) (string, error) {
+	keys := make(map[string]any, len(orderBy))
+	for _, order := range orderBy {
+		keys[order.Column] = last.data[order.Column]
+	}
+	encoded, err := encodeRow(keys)
+	if err != nil {
+		return "", err
+	}
+	text, err := json.Marshal(cursor{Keys: encoded, ID: last.id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(text), nil
+}
+
+func decodeCursor(columns []*Column, s string, orderBy []OrderBy) (rowRef, error) {
+	text, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return rowRef{}, fmt.Errorf("invalid cursor")
+	}
+	var c cursor
+	if err := json.Unmarshal(text, &c); err != nil {
+		return rowRef{}, fmt.Errorf("invalid cursor")
+	}
+	if len(c.Keys) != len(orderBy) {
+		return rowRef{}, fmt.Errorf("cursor does not match ORDER BY")
+	}
+	for _, order := range orderBy {
+		if _, ok := c.Keys[order.Column]; !ok {
+			return rowRef{}, fmt.Errorf("cursor does not match ORDER BY")
+		}
+	}
+	data, err := decodeRow(columns, c.Keys)
+	if err != nil {
+		return rowRef{}, fmt.Errorf("invalid cursor: %v", err)
+	}
+	return rowRef{id: c.ID, data: data}, nil
+}
+
+// copyRow returns a copy of a stored row that shares no memory with it.
+func copyRow(row map[string]any) map[string]any {
+	copied := make(map[string]any, len(row))
+	for col, val := range row {
+		copied[col] = copyValue(val)
+	}
+	return copied
+}
+
+func copyValue(val any) any {
+	switch v := val.(type) {
+	case []byte:
+		return bytes.Clone(v)
+	case json.RawMessage:
+		return json.RawMessage(bytes.Clone(v))
+	}
+	return val
+}
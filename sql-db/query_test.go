@@ -0,0 +1,101 @@
+package sqldb
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func names(rows []map[string]any) []string {
+	var names []string
+	for _, row := range rows {
+		names = append(names, fmt.Sprint(row["name"]))
+	}
+	return names
+}
+
+func TestSelectOrderLimitOffset(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	tests := []struct {
+		opts QueryOptions
+		want []string
+	}{
+		{QueryOptions{OrderBy: []OrderBy{Asc("score")}}, []string{"dee", "eve", "ada", "bob", "cy"}},
+		{QueryOptions{OrderBy: []OrderBy{Desc("score"), Desc("name")}}, []string{"cy", "bob", "ada", "eve", "dee"}},
+		{QueryOptions{OrderBy: []OrderBy{Asc("team"), Desc("score")}}, []string{"bob", "dee", "cy", "ada", "eve"}},
+		{QueryOptions{OrderBy: []OrderBy{Asc("name")}, Limit: Limit(2), Offset: 1}, []string{"bob", "cy"}},
+		{QueryOptions{OrderBy: []OrderBy{Asc("name")}, Offset: 4}, []string{"eve"}},
+		{QueryOptions{Offset: 9}, nil},
+		{QueryOptions{Limit: Limit(0)}, nil},
+		{QueryOptions{Where: Eq("team", "red"), OrderBy: []OrderBy{Desc("score")}, Limit: Limit(1)}, []string{"cy"}},
+	}
+	for _, tt := range tests {
+		rs, err := db.Select("players", tt.opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		if got := names(rs.Rows); !slices.Equal(got, tt.want) {
+			t.Errorf("%+v = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestSelectProjectionAndCopies(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	rs, err := db.Select("players", QueryOptions{Columns: []string{"score", "name"}, Where: Eq("name", "ada")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rs.Columns, []string{"score", "name"}) || len(rs.Rows[0]) != 2 {
+		t.Fatalf("projection = %v %v", rs.Columns, rs.Rows)
+	}
+	rs.Rows[0]["name"] = "changed"
+	rows, _ := db.GetRecords("players", nil)
+	rows[1]["name"] = "changed"
+	if got := names(selectPlayers(t, db, QueryOptions{}).Rows); !slices.Equal(got, []string{"ada", "bob", "cy", "dee", "eve"}) {
+		t.Fatalf("changing results changed the table: %v", got)
+	}
+
+	if _, err := db.Select("players", QueryOptions{Columns: []string{"nope"}}); err == nil {
+		t.Fatal("projecting an unknown column succeeded")
+	}
+	if _, err := db.Select("players", QueryOptions{OrderBy: []OrderBy{Asc("nope")}}); err == nil {
+		t.Fatal("ordering by an unknown column succeeded")
+	}
+}
+
+func selectPlayers(t *testing.T, db *Database, opts QueryOptions) *ResultSet {
+	t.Helper()
+	rs, err := db.Select("players", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func TestSelectCursorPages(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "players", playerColumns(), playerRows()...)
+	opts := QueryOptions{OrderBy: []OrderBy{Desc("score")}, Limit: Limit(2)}
+	var got []string
+	for page := 0; ; page++ {
+		rs := selectPlayers(t, db, opts)
+		got = append(got, names(rs.Rows)...)
+		if rs.Cursor == "" {
+			break
+		}
+		if page == 0 {
+			// rows added ahead of the cursor don't shift the pages after it
+			db.InsertRecord("players", map[string]any{"name": "zed", "score": 9})
+		}
+		opts.After = rs.Cursor
+	}
+	if want := []string{"bob", "cy", "ada", "eve", "dee"}; !slices.Equal(got, want) {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+	if _, err := db.Select("players", QueryOptions{After: "not a cursor"}); err == nil {
+		t.Fatal("a malformed cursor was accepted")
+	}
+}
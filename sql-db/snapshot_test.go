@@ -0,0 +1,144 @@
+package sqldb
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	db := NewDatabase()
+	err := db.CreateTable("events", []*Column{
+		NewColumn("id", TypeInt, PrimaryKey()),
+		NewColumn("name", TypeString, Required(), MaxLength(8)),
+		NewColumn("at", TypeTimestamp),
+		NewColumn("payload", TypeBytes),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	db.InsertRecord("events", map[string]any{"id": 1, "name": "boot", "at": at, "payload": []byte{0, 1}})
+	db.InsertRecord("events", map[string]any{"id": 2, "name": "halt"})
+	db.DeleteRecords("events", map[string]any{"id": 2})
+	db.InsertRecord("events", map[string]any{"id": 3, "name": "wake"})
+	if err := db.CreateIndex("events", "at", OrderedIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := db.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, _ := loaded.GetByPrimaryKey("events", 1)
+	if row["name"] != "boot" || !row["at"].(time.Time).Equal(at) || !bytes.Equal(row["payload"].([]byte), []byte{0, 1}) {
+		t.Fatalf("loaded %v", row)
+	}
+	if n := rowCount(t, loaded, "events"); n != 2 {
+		t.Fatalf("loaded %d rows, want 2", n)
+	}
+	table, _ := loaded.GetTable("events")
+	if idx := table.index("at"); idx == nil || idx.Kind != OrderedIndex {
+		t.Fatal("index was not restored")
+	}
+	// constraints come back with the schema
+	if err := loaded.InsertRecord("events", map[string]any{"id": 4, "name": "too long a name"}); err == nil {
+		t.Fatal("loaded table accepts a name over its max length")
+	}
+	if err := loaded.InsertRecord("events", map[string]any{"id": 1, "name": "dup"}); err == nil {
+		t.Fatal("loaded table accepts a duplicate primary key")
+	}
+	// new rows don't reuse the ids of the loaded ones
+	if err := loaded.InsertRecord("events", map[string]any{"id": 5, "name": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := rowCount(t, loaded, "events"); n != 3 {
+		t.Fatalf("got %d rows after an insert, want 3", n)
+	}
+}
+
+func TestLoadSnapshotFixture(t *testing.T) {
+	fixture := `{"format": "sqldb", "version": 1, "tables": [{
+		"name": "users",
+		"columns": [{"name": "id", "type": "int", "primary_key": true, "required": true, "unique": true},
+			{"name": "name", "type": "string"}],
+		"rows": [{"id": 1, "name": "ada"}, {"id": 2, "name": "grace"}]
+	}]}`
+	db, err := LoadSnapshot(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.GetByPrimaryKey("users", 2); row["name"] != "grace" {
+		t.Fatalf("got %v, want grace", row)
+	}
+
+	for name, bad := range map[string]string{
+		"format":    strings.Replace(fixture, `"sqldb"`, `"other"`, 1),
+		"version":   strings.Replace(fixture, `"version": 1`, `"version": 2`, 1),
+		"duplicate": strings.Replace(fixture, `"id": 2`, `"id": 1`, 1),
+		"type":      strings.Replace(fixture, `"id": 2`, `"id": "two"`, 1),
+		"json":      fixture[:40],
+	} {
+		if _, err := LoadSnapshot(strings.NewReader(bad)); err == nil {
+			t.Errorf("loading a fixture with a bad %s succeeded", name)
+		}
+	}
+}
+
+func TestCompactKeepsState(t *testing.T) {
+	dir := openWithRows(t, 3)
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRecord("users", map[string]any{"id": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(walPath(dir, 0)); !os.IsNotExist(err) {
+		t.Fatalf("compacted log is still there: %v", err)
+	}
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n := rowCount(t, db, "users"); n != 4 {
+		t.Fatalf("got %d rows after reopening, want 4", n)
+	}
+}
+
+func TestSnapshotRejectsGoFunctions(t *testing.T) {
+	rowOK := func(map[string]any) error { return nil }
+	tests := map[string]struct {
+		columns []*Column
+		checks  []TableCheck
+	}{
+		"column check": {[]*Column{NewColumn("a", TypeInt, Check("c", func(any) error { return nil }))}, nil},
+		"computed":     {[]*Column{NewColumn("a", TypeInt, Computed(func(map[string]any) any { return 1 }))}, nil},
+		"default func": {[]*Column{NewColumn("a", TypeInt, DefaultFunc(func() any { return 1 }))}, nil},
+		"table check":  {[]*Column{NewColumn("a", TypeInt)}, []TableCheck{{Name: "c", Fn: rowOK}}},
+	}
+	for name, tt := range tests {
+		db := NewDatabase()
+		if err := db.CreateTable("t", tt.columns, tt.checks...); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var buf bytes.Buffer
+		if err := db.SaveSnapshot(&buf); err == nil || !strings.Contains(err.Error(), "can't be saved") {
+			t.Errorf("%s: SaveSnapshot = %v, want an error", name, err)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is what Insert and Select read and write through: a Database or a Tx.
+type Store interface {
+	InsertMany(tableName string, records []map[string]any) error
+	GetRecordsWhere(tableName string, pred Predicate) ([]map[string]any, error)
+}
+
+// CreateTableFromStruct creates a table with a column for every exported field of the
+// struct v, or the struct v points to. Fields of embedded structs are flattened.
+//
+// A field's column is named after the field unless its tag gives a name, and typed
+// after the field: strings, integers, floats, bools, time.Time, []byte and
+// json.RawMessage map onto the column types of the same kind, and any other type is
+// stored as JSON. Pointer fields hold NULL as nil. The tag lists constraints after the
+// name, e.g.
+//
+//	ID    int    `sqldb:"id,pk,autoincrement"`
+//	Name  string `sqldb:"name,required,unique,minlen=1,maxlen=20,pattern=^[a-z]+$"`
+//	Age   int    `sqldb:"age,min=18,max=130"`
+//	Role  string `sqldb:"role,enum=admin|user,default=user"`
+//	Owner *int   `sqldb:"owner,references=users,ondelete=cascade"`
+//	Notes string `sqldb:"-"`
+//
+// ondelete is restrict (the default), cascade or setnull. Values in a tag can't
+// contain commas. Insert leaves out a zero field tagged autoincrement, default= or
+// required, as a record missing the column; a pointer field stores zero values.
+func (db *Database) CreateTableFromStruct(name string, v any) error {
+	m, err := mappingOf(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	return db.CreateTable(name, m.columns())
+}
+
+// Insert adds the structs as records of a table, in one commit; see
+// CreateTableFromStruct for how fields map onto columns.
+func Insert[T any](store Store, tableName string, rows ...T) error {
+	m, err := mappingOf(reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+	records := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		record, err := m.record(reflect.ValueOf(row))
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	return store.InsertMany(tableName, records)
+}
+
+// Select returns the records of a table matching pred, or every record when pred is
+// nil, scanned into structs. Columns without a field are ignored.
+func Select[T any](store Store, tableName string, pred Predicate) ([]T, error) {
+	m, err := mappingOf(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.GetRecordsWhere(tableName, pred)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]T, len(records))
+	for i, record := range records {
+		if err := m.scan(reflect.ValueOf(&rows[i]).Elem(), record); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// structMapping maps the fields of a struct type onto columns.
+type structMapping struct {
+	pointer bool // the type is a pointer to the struct
+	fields  []fieldMapping
+}
+
+type fieldMapping struct {
+	index    []int
+	col      *Column
+	omitZero bool // a zero value is left out of records, see structMapping.record
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+
+	mappings sync.Map // reflect.Type -> *structMapping
+)
+
+func mappingOf(t reflect.Type) (*structMapping, error) {
+	if cached, ok := mappings.Load(t); ok {
+		return cached.(*structMapping), nil
+	}
+	m := &structMapping{}
+	st := t
+	if st != nil && st.Kind() == reflect.Pointer {
+		m.pointer = true
+		st = st.Elem()
+	}
+	if st == nil || st.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct but got %v", t)
+	}
+	for _, f := range reflect.VisibleFields(st) {
+		if !f.IsExported() || f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			continue
+		}
+		if throughPointer(st, f.Index) {
+			return nil, fmt.Errorf("field %s of %v is promoted through an embedded pointer", f.Name, st)
+		}
+		tag := f.Tag.Get("sqldb")
+		if tag == "-" {
+			continue
+		}
+		col, omitZero, err := fieldColumn(f, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %v: %v", f.Name, st, err)
+		}
+		m.fields = append(m.fields, fieldMapping{index: f.Index, col: col, omitZero: omitZero})
+	}
+	cached, _ := mappings.LoadOrStore(t, m)
+	return cached.(*structMapping), nil
+}
+
+func throughPointer(t reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		t = t.Field(i).Type
+		if t.Kind() == reflect.Pointer {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldColumn derives a column from a struct field and its sqldb tag. omitZero reports
+// whether the tag asks for the field's zero value to be left out of records, which
+// autoincrement, default= and required do.
+func fieldColumn(f reflect.StructField, tag string) (col *Column, omitZero bool, err error) {
+	options := strings.Split(tag, ",")
+	name := options[0]
+	if name == "" {
+		name = f.Name
+	}
+	colType, err := goColumnType(f.Type)
+	if err != nil {
+		return nil, false, err
+	}
+	col = NewColumn(name, colType)
+	cc := &col.Constraints
+	for _, option := range options[1:] {
+		key, val, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			Required()(cc)
+			omitZero = true
+		case "pk":
+			PrimaryKey()(cc)
+		case "unique":
+			Unique()(cc)
+		case "autoincrement":
+			AutoIncrement()(cc)
+			omitZero = true
+		case "default":
+			v, err := parseTagValue(colType, val)
+			if err != nil {
+				return nil, false, err
+			}
+			Default(v)(cc)
+			omitZero = true
+		case "min", "max", "minlen", "maxlen":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s needs an integer, got %q", key, val)
+			}
+			switch key {
+			case "min":
+				MinValue(n)(cc)
+			case "max":
+				MaxValue(n)(cc)
+			case "minlen":
+				MinLength(n)(cc)
+			case "maxlen":
+				MaxLength(n)(cc)
+			}
+		case "pattern":
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid pattern %q: %v", val, err)
+			}
+			cc.Pattern = re
+		case "enum":
+			for _, text := range strings.Split(val, "|") {
+				v, err := parseTagValue(colType, text)
+				if err != nil {
+					return nil, false, err
+				}
+				cc.Enum = append(cc.Enum, v)
+			}
+		case "references":
+			References(val, Restrict)(cc)
+		case "ondelete":
+			if cc.References == nil {
+				return nil, false, fmt.Errorf("ondelete needs references")
+			}
+			switch val {
+			case "restrict":
+				cc.References.OnDelete = Restrict
+			case "cascade":
+				cc.References.OnDelete = Cascade
+			case "setnull":
+				cc.References.OnDelete = SetNull
+			default:
+				return nil, false, fmt.Errorf("unknown ondelete action %q", val)
+			}
+		default:
+			return nil, false, fmt.Errorf("unknown tag option %q", option)
+		}
+	}
+	return col, omitZero, nil
+}
+
+func goColumnType(t reflect.Type) (ColumnType, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return TypeTimestamp, nil
+	case t == rawType:
+		return TypeJSON, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return TypeBytes, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt, nil
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat, nil
+	case reflect.Bool:
+		return TypeBool, nil
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return TypeJSON, nil
+	}
+	return "", fmt.Errorf("unsupported type %v", t)
+}
+
+func parseTagValue(colType ColumnType, text string) (any, error) {
+	switch colType {
+	case TypeInt:
+		return strconv.ParseInt(text, 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(text, 64)
+	case TypeBool:
+		return strconv.ParseBool(text)
+	case TypeString:
+		return text, nil
+	}
+	return nil, fmt.Errorf("tag values are not supported for %s columns", colType)
+}
+
+func (m *structMapping) columns() []*Column {
+	columns := make([]*Column, len(m.fields))
+	for i, f := range m.fields {
+		// tables keep their columns, so every table gets copies
+		col := *f.col
+		columns[i] = &col
+	}
+	return columns
+}
+
+// record turns a struct into a record; nil pointers, slices and maps are left out,
+// i.e. NULL. So are zero fields tagged autoincrement, default= or required, which get
+// generated, get their default or are rejected as missing, as they would be if a
+// record left them out.
+func (m *structMapping) record(v reflect.Value) (map[string]any, error) {
+	if m.pointer {
+		if v.IsNil() {
+			return nil, fmt.Errorf("can not insert a nil %v", v.Type())
+		}
+		v = v.Elem()
+	}
+	record := make(map[string]any, len(m.fields))
+	for _, f := range m.fields {
+		fv := v.FieldByIndex(f.index)
+		switch fv.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if fv.IsNil() {
+				continue
+			}
+		}
+		if f.omitZero && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			fv = fv.Elem()
+		}
+		val, err := fieldValue(f.col, fv)
+		if err != nil {
+			return nil, err
+		}
+		record[f.col.Name] = val
+	}
+	return record, nil
+}
+
+func fieldValue(col *Column, fv reflect.Value) (any, error) {
+	switch col.Type {
+	case TypeString:
+		return fv.String(), nil
+	case TypeInt:
+		// int columns store int64, see Column.convert
+		if fv.CanInt() {
+			return fv.Int(), nil
+		}
+		if fv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("column %s: %d is out of range for int", col.Name, fv.Uint())
+		}
+		return int64(fv.Uint()), nil
+	case TypeFloat:
+		return fv.Float(), nil
+	case TypeBool:
+		return fv.Bool(), nil
+	case TypeBytes:
+		return fv.Bytes(), nil
+	case TypeJSON:
+		if fv.Type() == rawType {
+			return fv.Interface(), nil
+		}
+		raw, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		return json.RawMessage(raw), nil
+	}
+	return fv.Interface(), nil
+}
+
+// scan sets the fields of v, a struct or a pointer to one, from a record.
+func (m *structMapping) scan(v reflect.Value, record map[string]any) error {
+	if m.pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	for _, f := range m.fields {
+		val := record[f.col.Name]
+		fv := v.FieldByIndex(f.index)
+		if val == nil {
+			fv.SetZero()
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if err := setField(fv, val); err != nil {
+			return fmt.Errorf("column %s: %v", f.col.Name, err)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, val any) error {
+	if raw, ok := val.(json.RawMessage); ok && fv.Type() != rawType {
+		return json.Unmarshal(raw, fv.Addr().Interface())
+	}
+	rv := reflect.ValueOf(val)
+	switch {
+	case fv.CanInt() && rv.CanInt():
+		if fv.OverflowInt(rv.Int()) {
+			return fmt.Errorf("%v overflows %v", val, fv.Type())
+		}
+		fv.SetInt(rv.Int())
+	case fv.CanInt() && rv.CanUint():
+		n := rv.Uint()
+		if int64(n) < 0 || fv.OverflowInt(int64(n)) {
+			return fmt.Errorf("%v overflows %v", val, fv.Type())
+		}
+		fv.SetInt(int64(n))
+	case fv.CanUint() && rv.CanInt():
+		if rv.Int() < 0 || fv.OverflowUint(uint64(rv.Int())) {
+			return fmt.Errorf("%v overflows %v", val, fv.Type())
+		}
+		fv.SetUint(uint64(rv.Int()))
+	case fv.CanUint() && rv.CanUint():
+		if fv.OverflowUint(rv.Uint()) {
+			return fmt.Errorf("%v overflows %v", val, fv.Type())
+		}
+		fv.SetUint(rv.Uint())
+	case fv.CanFloat() && rv.CanFloat():
+		fv.SetFloat(rv.Float())
+	case fv.CanFloat() && rv.CanInt():
+		fv.SetFloat(float64(rv.Int()))
+	case rv.Kind() == fv.Kind() && rv.Type().ConvertibleTo(fv.Type()):
+		fv.Set(rv.Convert(fv.Type()))
+	default:
+		return fmt.Errorf("can not scan %T into %v", val, fv.Type())
+	}
+	return nil
+}
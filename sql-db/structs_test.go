@@ -0,0 +1,86 @@
+package sqldb
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+type counter struct {
+	ID    int    `sqldb:"id,pk"`
+	Small int8   `sqldb:"small"`
+	Hits  uint64 `sqldb:"hits"`
+	Limit int    `sqldb:"limit,default=5"`
+	Note  *string
+}
+
+func TestStructIntsStoreOneType(t *testing.T) {
+	db := NewDatabase()
+	if err := db.CreateTableFromStruct("counters", counter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Insert(db, "counters", counter{ID: 1, Small: -3, Hits: 42, Limit: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRecord("counters", map[string]any{"id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := db.GetRecords("counters", nil)
+	for _, row := range rows {
+		for _, col := range []string{"id", "small", "hits", "limit"} {
+			if val, ok := row[col]; ok {
+				if _, ok := val.(int64); !ok {
+					t.Errorf("column %s holds %T, want int64", col, val)
+				}
+			}
+		}
+	}
+
+	got, err := Select[counter](db, "counters", Eq("id", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Small != -3 || got[0].Hits != 42 || got[0].Limit != 9 || got[0].Note != nil {
+		t.Fatalf("Select = %+v", got)
+	}
+}
+
+func TestStructUintOverflow(t *testing.T) {
+	db := NewDatabase()
+	db.CreateTableFromStruct("counters", counter{})
+	if err := Insert(db, "counters", counter{ID: 1, Hits: math.MaxUint64}); err == nil {
+		t.Fatal("inserting a uint64 above MaxInt64 succeeded")
+	}
+	if n := rowCount(t, db, "counters"); n != 0 {
+		t.Fatalf("got %d rows, want none", n)
+	}
+}
+
+func TestStructZeroFieldsAreMissing(t *testing.T) {
+	type account struct {
+		ID    int    `sqldb:"id,pk,autoincrement"`
+		Name  string `sqldb:"name,required"`
+		Role  string `sqldb:"role,default=user"`
+		Quota *int   `sqldb:"quota,default=10"`
+	}
+	db := NewDatabase()
+	if err := db.CreateTableFromStruct("accounts", account{}); err != nil {
+		t.Fatal(err)
+	}
+	zero := 0
+	if err := Insert(db, "accounts", account{Name: "ada"}, account{Name: "bob", Role: "admin", Quota: &zero}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Select[account](db, "accounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[0].Role != "user" || *got[0].Quota != 10 ||
+		got[1].Role != "admin" || *got[1].Quota != 0 {
+		t.Fatalf("Select = %+v", got)
+	}
+	var violation *ConstraintViolation
+	if err := Insert(db, "accounts", account{Role: "admin"}); !errors.As(err, &violation) || violation.Constraint != ConstraintRequired {
+		t.Fatalf("inserting an empty required name = %v, want a not null violation", err)
+	}
+}
@@ -1,82 +1,368 @@
 package sqldb
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
 
+// Table stores every row as a chain of immutable versions (see rowVersion).
+//
+// Readers load the published version slice and filter it by their snapshot, so they
+// never wait for writers. Writers of the same table are serialised by mu.
 type Table struct {
 	Name    string
 	Columns []*Column
-	Rows    []map[string]any
+	Checks  []TableCheck
+
+	mu       sync.Mutex                    // held by writers for validation and commit
+	db       *Database                     // nil for a table outside any database
+	tm       *txManager                    // shared by all tables of a database
+	versions atomic.Pointer[[]*rowVersion] // append-only, replaced wholesale by vacuum
+	nextID   atomic.Int64
+	autoInc  atomic.Int64 // last value of the auto-increment column, see AutoIncrement
+	dead     int          // superseded versions not yet vacuumed, guarded by mu
+	stats    atomic.Pointer[tableStats]
+
+	idxMu   sync.RWMutex      // guards the index map and the indexes themselves
+	indexes map[string]*Index // column name -> index
+
+	triggers atomic.Pointer[[]Trigger] // replaced wholesale under mu
 }
 
-func NewTable(name string, Columns []*Column) *Table {
-	return &Table{
+// rowRef is a row as seen by a snapshot, possibly overlaid with uncommitted changes.
+type rowRef struct {
+	id      int64
+	data    map[string]any
+	version *rowVersion // committed version the row is based on, nil for uncommitted inserts
+}
+
+func NewTable(name string, Columns []*Column, checks ...TableCheck) *Table {
+	t := &Table{
 		Name:    name,
 		Columns: Columns,
-		Rows:    []map[string]any{},
+		Checks:  checks,
+		tm:      newTxManager(),
+		indexes: make(map[string]*Index),
+	}
+	t.versions.Store(&[]*rowVersion{})
+	// unique columns are backed by a hash index used to detect duplicates, foreign
+	// keys by one used to find the rows referencing a deleted row
+	for _, col := range Columns {
+		if col.Constraints.Unique || col.Constraints.References != nil {
+			t.indexes[col.Name] = newIndex(col, HashIndex)
+		}
+	}
+	return t
+}
+
+// modify runs fn in a transaction of its own and commits it. Tables of a database
+// go through the database, which also locks the tables fn may cascade into.
+func (t *Table) modify(fn func(tx *Tx) error) error {
+	if t.db != nil {
+		return t.db.autocommit(t, fn)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// no other writer can touch the table, so the latest commit is a stable snapshot;
+	// triggers can only reach the table itself
+	tx := &Tx{snapshot: t.tm.committed.Load(), writes: make(map[string]*writeSet), autocommit: true}
+	if err := fn(tx); err != nil {
+		return err
 	}
+	return tx.commitLocked()
+}
+
+// read runs fn against a pinned snapshot of the latest commit.
+func (t *Table) read(fn func(snapshot uint64)) {
+	snapshot := t.tm.acquireSnapshot()
+	defer t.tm.releaseSnapshot(snapshot)
+	fn(snapshot)
 }
 
 func (t *Table) AddRow(r map[string]any) error {
-	//col is fixed - so check for value of each col
-	// range over col name
+	return t.AddRows([]map[string]any{r})
+}
+
+// AddRows validates every row, including uniqueness across the batch, before adding any of them.
+func (t *Table) AddRows(rows []map[string]any) error {
+	_, err := t.addRows(rows)
+	return err
+}
+
+// addRows is AddRows, also returning the auto-increment value of the last row.
+func (t *Table) addRows(rows []map[string]any) (int64, error) {
+	var id int64
+	err := t.modify(func(tx *Tx) error {
+		var err error
+		id, err = tx.insert(t, rows)
+		return err
+	})
+	return id, err
+}
+
+func (t *Table) PrimaryKey() *Column {
+	return primaryKey(t.Columns)
+}
 
+func primaryKey(columns []*Column) *Column {
+	for _, col := range columns {
+		if col.Constraints.PrimaryKey {
+			return col
+		}
+	}
+	return nil
+}
+
+// GetByPrimaryKey returns the row whose primary key equals key, or nil if there is none.
+func (t *Table) GetByPrimaryKey(key any) (map[string]any, error) {
+	var row map[string]any
+	var err error
+	t.read(func(snapshot uint64) {
+		row, err = t.getByPrimaryKey(snapshot, key, nil)
+	})
+	return row, err
+}
+
+func (t *Table) getByPrimaryKey(snapshot uint64, key any, ws *writeSet) (map[string]any, error) {
+	pk := t.PrimaryKey()
+	if pk == nil {
+		return nil, fmt.Errorf("table %s has no primary key", t.Name)
+	}
+	refs := t.scan(snapshot, Eq(pk.Name, key), ws)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	return copyRow(refs[0].data), nil
+}
+
+// prepareRow validates r and returns a copy holding each value in its column's stored
+// representation. It reports every problem with the row, joined with errors.Join.
+func (t *Table) prepareRow(r map[string]any) (map[string]any, error) {
+	var errs []error
+	safeCopy := make(map[string]any, len(r))
 	for _, col := range t.Columns {
 		value, ok := r[col.Name]
 		if !ok {
 			if col.Constraints.Required {
-				return fmt.Errorf("required column %s is missing", col.Name)
+				errs = append(errs, col.violation(t.Name, ConstraintRequired, nil, "required column is missing"))
 			}
 			continue
 		}
-		// validation for that col
-		// if not working - return error
-		if err := col.Validate(value); err != nil {
-			return err
+		converted, violations := col.coerce(t.Name, value)
+		errs = append(errs, violations...)
+		if converted != nil {
+			safeCopy[col.Name] = converted
 		}
 	}
 
-	// check for unknown column
-	for colName := range r {
-		found := false
-		for _, col := range t.Columns {
-			if col.Name == colName {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("unkown column %s", colName)
+	for _, colName := range sortedNames(r) {
+		if t.GetColumn(colName) == nil {
+			errs = append(errs, unknownColumn(colName))
 		}
 	}
-	safeCopy := make(map[string]any)
-	for col, value := range r {
-		safeCopy[col] = value
+	if len(errs) == 0 {
+		// table checks can rely on every column being valid
+		errs = t.checkRow(safeCopy)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return safeCopy, nil
+}
+
+func (t *Table) GetColumn(name string) *Column {
+	return findColumn(t.Columns, name)
+}
+
+func findColumn(columns []*Column, name string) *Column {
+	for _, col := range columns {
+		if col.Name == name {
+			return col
+		}
 	}
-	t.Rows = append(t.Rows, safeCopy)
-	fmt.Printf("1 Row added successfully.\n")
 	return nil
 }
 
 func (t *Table) GetRows(filter map[string]any) []map[string]any {
-	// select  *
-	if len(filter) == 0 {
-		return t.Rows
-	}
-
-	// loop over table - check each row
-	var matchedRows []map[string]any
-	for _, row := range t.Rows {
-		matches := true
-		for col, val := range filter {
-			rowVal, ok := row[col]
-			if !ok || val != rowVal {
-				matches = false
-				break
+	return t.GetRowsWhere(FilterFromMap(filter))
+}
+
+// GetRowsWhere returns the rows matching pred, or every row when pred is nil.
+func (t *Table) GetRowsWhere(pred Predicate) []map[string]any {
+	var rows []map[string]any
+	t.read(func(snapshot uint64) {
+		rows = refsToRows(t.scan(snapshot, pred, nil))
+	})
+	return rows
+}
+
+// scan returns the rows matching pred as seen by snapshot with the uncommitted changes
+// in ws (which may be nil) applied on top, ordered by row id, i.e. insertion order.
+func (t *Table) scan(snapshot uint64, pred Predicate, ws *writeSet) []rowRef {
+	refs, _ := t.scanPath(snapshot, pred, ws)
+	return refs
+}
+
+// scanPath is scan, also returning the access path the planner chose, nil for a
+// full scan.
+func (t *Table) scanPath(snapshot uint64, pred Predicate, ws *writeSet) ([]rowRef, *accessPath) {
+	t.idxMu.RLock()
+	path := t.planAccess(pred)
+	var candidates []*rowVersion
+	if path != nil {
+		candidates = t.fetch(path)
+	}
+	t.idxMu.RUnlock()
+	if path == nil {
+		candidates = *t.versions.Load()
+	}
+
+	var refs []rowRef
+	for _, v := range candidates {
+		if !v.visible(snapshot) {
+			continue
+		}
+		if ws != nil {
+			if _, changed := ws.rows[v.id]; changed {
+				continue
+			}
+		}
+		if rowMatches(v.data, pred) {
+			refs = append(refs, rowRef{id: v.id, data: v.data, version: v})
+		}
+	}
+	if ws != nil {
+		for id, p := range ws.rows {
+			if p.data != nil && rowMatches(p.data, pred) {
+				refs = append(refs, rowRef{id: id, data: p.data, version: p.base})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].id < refs[j].id })
+	return refs, path
+}
+
+// refsToRows copies the rows out, so callers can't modify stored versions.
+func refsToRows(refs []rowRef) []map[string]any {
+	rows := make([]map[string]any, 0, len(refs))
+	for _, ref := range refs {
+		rows = append(rows, copyRow(ref.data))
+	}
+	return rows
+}
+
+func (t *Table) UpdateRows(filter map[string]any, changes map[string]any) (int, error) {
+	return t.UpdateRowsWhere(FilterFromMap(filter), changes)
+}
+
+// UpdateRowsWhere applies changes to every row matching pred and returns the number of rows updated.
+// Every updated row is validated before any of them is modified.
+func (t *Table) UpdateRowsWhere(pred Predicate, changes map[string]any) (int, error) {
+	var n int
+	err := t.modify(func(tx *Tx) error {
+		var err error
+		n, err = tx.updateWhere(t, pred, changes)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (t *Table) DeleteRows(filter map[string]any) (int, error) {
+	return t.DeleteRowsWhere(FilterFromMap(filter))
+}
+
+// DeleteRowsWhere removes every row matching pred and returns the number of rows removed.
+func (t *Table) DeleteRowsWhere(pred Predicate) (int, error) {
+	var n int
+	err := t.modify(func(tx *Tx) error {
+		var err error
+		n, err = tx.deleteWhere(t, pred)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// RowCount returns the number of rows visible to a new reader.
+func (t *Table) RowCount() int {
+	count := 0
+	t.read(func(snapshot uint64) {
+		for _, v := range *t.versions.Load() {
+			if v.visible(snapshot) {
+				count++
 			}
 		}
-		if matches {
-			matchedRows = append(matchedRows, row)
+	})
+	return count
+}
+
+// Vacuum drops row versions that no active snapshot can see any more.
+func (t *Table) Vacuum() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.vacuum()
+}
+
+// maybeVacuum vacuums once superseded versions make up most of the table. The caller
+// must hold t.mu.
+func (t *Table) maybeVacuum() {
+	if t.dead > 1024 && t.dead > len(*t.versions.Load())/2 {
+		t.vacuum()
+	}
+}
+
+func (t *Table) vacuum() {
+	horizon := t.tm.horizon()
+	old := *t.versions.Load()
+	kept := make([]*rowVersion, 0, len(old)-t.dead)
+	dead := 0
+	for _, v := range old {
+		xmax := v.xmax.Load()
+		switch {
+		case xmax == 0:
+			kept = append(kept, v)
+		case xmax > horizon:
+			kept = append(kept, v)
+			dead++
 		}
 	}
-	return matchedRows
+
+	t.idxMu.Lock()
+	for column, idx := range t.indexes {
+		rebuilt := newIndex(idx.col, idx.Kind)
+		for _, v := range kept {
+			rebuilt.insert(v)
+		}
+		t.indexes[column] = rebuilt
+	}
+	t.idxMu.Unlock()
+	t.versions.Store(&kept)
+	t.dead = dead
+}
+
+// checkPredicate makes sure pred only references columns of the table.
+func (t *Table) checkPredicate(pred Predicate) error {
+	return checkPredicateColumns(t.Columns, pred)
+}
+
+func checkPredicateColumns(columns []*Column, pred Predicate) error {
+	for _, colName := range predicateColumns(pred) {
+		if findColumn(columns, colName) == nil {
+			return unknownColumn(colName)
+		}
+	}
+	return nil
+}
+
+func rowMatches(row map[string]any, pred Predicate) bool {
+	return pred == nil || pred.Match(row)
 }
@@ -0,0 +1,59 @@
+package sqldb
+
+import "testing"
+
+func TestTableUpdateRows(t *testing.T) {
+	table := createTable(t, NewDatabase(), "scores", []*Column{
+		NewColumn("name", TypeString, Required()),
+		NewColumn("team", TypeString),
+		NewColumn("score", TypeInt, MaxValue(100)),
+	},
+		map[string]any{"name": "ada", "team": "red", "score": 10},
+		map[string]any{"name": "bob", "team": "red", "score": 20},
+		map[string]any{"name": "cy", "team": "blue", "score": 30},
+	)
+	n, err := table.UpdateRows(map[string]any{"team": "red"}, map[string]any{"score": 50})
+	if err != nil || n != 2 {
+		t.Fatalf("UpdateRows = %d, %v, want 2 rows", n, err)
+	}
+	if rows := table.GetRows(map[string]any{"score": 50}); len(rows) != 2 {
+		t.Fatalf("got %d updated rows, want 2", len(rows))
+	}
+	if rows := table.GetRows(map[string]any{"name": "cy"}); rows[0]["score"] != int64(30) {
+		t.Fatalf("row outside the filter changed: %v", rows[0])
+	}
+
+	// every row is validated before any is changed
+	if _, err := table.UpdateRows(nil, map[string]any{"score": 101}); err == nil {
+		t.Fatal("update breaking max value succeeded")
+	}
+	if _, err := table.UpdateRows(nil, map[string]any{"name": nil}); err == nil {
+		t.Fatal("update clearing a required column succeeded")
+	}
+	if _, err := table.UpdateRows(nil, map[string]any{"nope": 1}); err == nil {
+		t.Fatal("update of an unknown column succeeded")
+	}
+	if rows := table.GetRows(map[string]any{"score": 50}); len(rows) != 2 {
+		t.Fatal("a failed update changed rows")
+	}
+}
+
+func TestTableDeleteRows(t *testing.T) {
+	table := createTable(t, NewDatabase(), "scores", []*Column{
+		NewColumn("name", TypeString),
+		NewColumn("team", TypeString),
+	},
+		map[string]any{"name": "ada", "team": "red"},
+		map[string]any{"name": "bob", "team": "red"},
+		map[string]any{"name": "cy", "team": "blue"},
+	)
+	if n, err := table.DeleteRows(map[string]any{"team": "red"}); err != nil || n != 2 {
+		t.Fatalf("DeleteRows = %d, %v, want 2 rows", n, err)
+	}
+	if n, err := table.DeleteRows(map[string]any{"team": "red"}); err != nil || n != 0 {
+		t.Fatalf("deleting again = %d, %v, want no rows", n, err)
+	}
+	if n := table.RowCount(); n != 1 {
+		t.Fatalf("got %d rows, want 1", n)
+	}
+}
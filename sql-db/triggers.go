@@ -0,0 +1,147 @@
+package sqldb
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TriggerTiming says whether a trigger runs before or after the change of a row.
+type TriggerTiming string
+
+const (
+	Before TriggerTiming = "before"
+	After  TriggerTiming = "after"
+)
+
+// maxTriggerDepth bounds triggers firing triggers, e.g. one updating its own table.
+const maxTriggerDepth = 16
+
+// Trigger runs Fn for every row an insert, update or delete (Op) of its table changes,
+// inside the transaction making the change. Before triggers run while the statement
+// validates its rows, and may modify change.New of an insert or update before it is
+// validated; after triggers run once the statement has changed all its rows. An error
+// from either rejects the whole statement.
+//
+// tx is the transaction of the change, for reading and writing any table of the
+// database; it is only valid while Fn runs, and can't be committed or rolled back.
+type Trigger struct {
+	Name   string
+	Timing TriggerTiming
+	Op     ChangeOp
+	Fn     func(tx *Tx, change *RowChange) error
+}
+
+// RowChange is the row a trigger runs for. Old is nil for an insert and New for a
+// delete; Old is a copy.
+type RowChange struct {
+	Table string
+	Op    ChangeOp
+	Old   map[string]any
+	New   map[string]any
+}
+
+// AddTrigger registers a trigger, which runs after the table's other triggers of the
+// same timing and operation. It waits for the table's running writes, so it must not be
+// called from a trigger. Triggers are Go functions, so they are not persisted: a
+// database opened from disk has none until they are added again. AlterTable keeps them.
+func (t *Table) AddTrigger(trigger Trigger) error {
+	switch {
+	case trigger.Name == "":
+		return fmt.Errorf("triggers must be named")
+	case trigger.Fn == nil:
+		return fmt.Errorf("trigger %s has no function", trigger.Name)
+	case trigger.Timing != Before && trigger.Timing != After:
+		return fmt.Errorf("trigger %s has unknown timing %q", trigger.Name, trigger.Timing)
+	case trigger.Op != ChangeInsert && trigger.Op != ChangeUpdate && trigger.Op != ChangeDelete:
+		return fmt.Errorf("trigger %s has unknown operation %q", trigger.Name, trigger.Op)
+	}
+	// autocommit writes check for triggers once they hold mu, see lockWriters, so the
+	// table must not gain one while they run
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	triggers := t.Triggers()
+	for _, existing := range triggers {
+		if existing.Name == trigger.Name {
+			return fmt.Errorf("trigger %s already exists on table %s", trigger.Name, t.Name)
+		}
+	}
+	triggers = append(triggers, trigger)
+	t.triggers.Store(&triggers)
+	return nil
+}
+
+func (t *Table) DropTrigger(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	triggers := t.Triggers()
+	i := slices.IndexFunc(triggers, func(trigger Trigger) bool { return trigger.Name == name })
+	if i < 0 {
+		return fmt.Errorf("unknown trigger %s", name)
+	}
+	triggers = slices.Delete(triggers, i, i+1)
+	t.triggers.Store(&triggers)
+	return nil
+}
+
+// Triggers returns the triggers of the table in the order they run.
+func (t *Table) Triggers() []Trigger {
+	if triggers := t.triggers.Load(); triggers != nil {
+		return slices.Clone(*triggers)
+	}
+	return nil
+}
+
+func (t *Table) hasAnyTriggers() bool {
+	triggers := t.triggers.Load()
+	return triggers != nil && len(*triggers) > 0
+}
+
+func (t *Table) hasTriggers(timing TriggerTiming, op ChangeOp) bool {
+	if triggers := t.triggers.Load(); triggers != nil {
+		for _, trigger := range *triggers {
+			if trigger.Timing == timing && trigger.Op == op {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fire runs the triggers of t for timing and change.Op on one row.
+func (tx *Tx) fire(t *Table, timing TriggerTiming, change *RowChange) error {
+	triggers := t.triggers.Load()
+	if triggers == nil {
+		return nil
+	}
+	for _, trigger := range *triggers {
+		if trigger.Timing != timing || trigger.Op != change.Op {
+			continue
+		}
+		if tx.depth == maxTriggerDepth {
+			return fmt.Errorf("trigger %s: triggers nested more than %d deep", trigger.Name, maxTriggerDepth)
+		}
+		if err := trigger.Fn(tx.nested(), change); err != nil {
+			return fmt.Errorf("trigger %s: %w", trigger.Name, err)
+		}
+	}
+	return nil
+}
+
+// before returns the hook running the before triggers of t for op in a write set, or
+// nil if there are none.
+func (tx *Tx) before(t *Table, op ChangeOp) func(*RowChange) error {
+	if !t.hasTriggers(Before, op) {
+		return nil
+	}
+	return func(change *RowChange) error {
+		return tx.fire(t, Before, change)
+	}
+}
+
+// nested returns the transaction a trigger runs in: tx, usable from inside the
+// statement that fired the trigger. Its statements are undone on their own if they
+// fail, and with the firing statement if that fails.
+func (tx *Tx) nested() *Tx {
+	// the firing statement holds the catalog lock, as autocommit callers do
+	return &Tx{db: tx.db, snapshot: tx.snapshot, writes: tx.writes, autocommit: true, depth: tx.depth + 1}
+}
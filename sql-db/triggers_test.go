@@ -0,0 +1,194 @@
+package sqldb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blogSQL creates a user and a posts table whose rows cascade from users.
+const blogSQL = `
+	CREATE TABLE users (id INT PRIMARY KEY, posts INT DEFAULT 0);
+	CREATE TABLE posts (id INT PRIMARY KEY, user_id INT REFERENCES users ON DELETE CASCADE, title STRING, updated_at TIMESTAMP);
+	INSERT INTO users (id) VALUES (1);
+`
+
+// countPosts keeps users.posts equal to the number of posts of the user.
+func countPosts(delta int64) func(tx *Tx, change *RowChange) error {
+	return func(tx *Tx, change *RowChange) error {
+		row := change.New
+		if row == nil {
+			row = change.Old
+		}
+		user, err := tx.GetByPrimaryKey("users", row["user_id"])
+		if err != nil {
+			return err
+		}
+		n, _ := convertToInt(user["posts"])
+		_, err = tx.UpdateRecords("users", map[string]any{"id": row["user_id"]}, map[string]any{"posts": n + delta})
+		return err
+	}
+}
+
+func userPosts(t *testing.T, db *Database) int64 {
+	t.Helper()
+	user, err := db.GetByPrimaryKey("users", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := convertToInt(user["posts"])
+	return n
+}
+
+func TestBeforeTriggerModifiesRow(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, blogSQL)
+	posts, _ := db.GetTable("posts")
+	stamp := time.Unix(100, 0).UTC()
+	err := posts.AddTrigger(Trigger{Name: "touch", Timing: Before, Op: ChangeUpdate, Fn: func(tx *Tx, change *RowChange) error {
+		change.New["updated_at"] = stamp
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.InsertRecord("posts", map[string]any{"id": 1, "user_id": 1, "title": "a"})
+	if _, err := db.UpdateRecords("posts", map[string]any{"id": 1}, map[string]any{"title": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	post, _ := db.GetByPrimaryKey("posts", 1)
+	if got, _ := post["updated_at"].(time.Time); !got.Equal(stamp) {
+		t.Fatalf("updated_at = %v, want %v", post["updated_at"], stamp)
+	}
+}
+
+func TestAfterTriggersWriteInTheSameTransaction(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, blogSQL)
+	posts, _ := db.GetTable("posts")
+	posts.AddTrigger(Trigger{Name: "count_insert", Timing: After, Op: ChangeInsert, Fn: countPosts(1)})
+	posts.AddTrigger(Trigger{Name: "count_delete", Timing: After, Op: ChangeDelete, Fn: countPosts(-1)})
+
+	tx := db.Begin()
+	tx.InsertRecord("posts", map[string]any{"id": 1, "user_id": 1})
+	tx.InsertRecord("posts", map[string]any{"id": 2, "user_id": 1})
+	if n := userPosts(t, db); n != 0 {
+		t.Fatalf("trigger writes are visible before commit: posts = %d", n)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := userPosts(t, db); n != 0 {
+		t.Fatalf("posts = %d after rollback, want 0", n)
+	}
+
+	db.InsertRecord("posts", map[string]any{"id": 1, "user_id": 1})
+	db.InsertRecord("posts", map[string]any{"id": 2, "user_id": 1})
+	db.DeleteRecords("posts", map[string]any{"id": 1})
+	if n := userPosts(t, db); n != 1 {
+		t.Fatalf("posts = %d, want 1", n)
+	}
+}
+
+func TestTriggerRejectsStatement(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, blogSQL)
+	posts, _ := db.GetTable("posts")
+	posts.AddTrigger(Trigger{Name: "count_insert", Timing: After, Op: ChangeInsert, Fn: countPosts(1)})
+	posts.AddTrigger(Trigger{Name: "no_spam", Timing: After, Op: ChangeInsert, Fn: func(tx *Tx, change *RowChange) error {
+		if change.New["title"] == "spam" {
+			return errors.New("spam")
+		}
+		return nil
+	}})
+
+	tx := db.Begin()
+	if err := tx.InsertRecord("posts", map[string]any{"id": 1, "user_id": 1, "title": "spam"}); err == nil {
+		t.Fatal("insert rejected by a trigger succeeded")
+	}
+	// the rejected statement is undone with the writes of its triggers, the rest stays
+	if err := tx.InsertRecord("posts", map[string]any{"id": 2, "user_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rowCount(t, db, "posts"); n != 1 {
+		t.Fatalf("got %d posts, want 1", n)
+	}
+	if n := userPosts(t, db); n != 1 {
+		t.Fatalf("posts = %d, want 1", n)
+	}
+}
+
+func TestTriggerRecursionIsBounded(t *testing.T) {
+	db := NewDatabase()
+	db.CreateTable("events", []*Column{NewColumn("id", TypeInt)})
+	events, _ := db.GetTable("events")
+	events.AddTrigger(Trigger{Name: "again", Timing: After, Op: ChangeInsert, Fn: func(tx *Tx, change *RowChange) error {
+		return tx.InsertRecord("events", map[string]any{"id": 1})
+	}})
+	if err := db.InsertRecord("events", map[string]any{"id": 0}); err == nil {
+		t.Fatal("endless trigger recursion succeeded")
+	}
+	if n := rowCount(t, db, "events"); n != 0 {
+		t.Fatalf("got %d rows, want none", n)
+	}
+}
+
+func TestAddTriggerDuringWrites(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, blogSQL)
+	posts, _ := db.GetTable("posts")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			if err := db.InsertRecord("posts", map[string]any{"id": i, "user_id": 1}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	// the trigger writes to a table the writes above don't lock unless they see it
+	db.CreateTable("log", []*Column{NewColumn("post_id", TypeInt)})
+	posts.AddTrigger(Trigger{Name: "log", Timing: After, Op: ChangeInsert, Fn: func(tx *Tx, change *RowChange) error {
+		return tx.InsertRecord("log", map[string]any{"post_id": change.New["id"]})
+	}})
+	wg.Wait()
+
+	// every post inserted once the trigger was in place is logged, so the logged posts
+	// are the last ones inserted
+	logged, _ := db.GetRecords("log", nil)
+	first := int64(200)
+	for _, row := range logged {
+		id, _ := convertToInt(row["post_id"])
+		first = min(first, id)
+	}
+	if want := 200 - int(first); len(logged) != want {
+		t.Fatalf("logged %d posts from post %d on, want %d", len(logged), first, want)
+	}
+}
+
+func TestDropTrigger(t *testing.T) {
+	db := NewDatabase()
+	mustExec(t, db, blogSQL)
+	posts, _ := db.GetTable("posts")
+	posts.AddTrigger(Trigger{Name: "count_insert", Timing: After, Op: ChangeInsert, Fn: countPosts(1)})
+	db.InsertRecord("posts", map[string]any{"id": 1, "user_id": 1})
+	if err := posts.DropTrigger("count_insert"); err != nil {
+		t.Fatal(err)
+	}
+	db.InsertRecord("posts", map[string]any{"id": 2, "user_id": 1})
+	if n := userPosts(t, db); n != 1 {
+		t.Fatalf("posts = %d, want 1 as the trigger was dropped after the first insert", n)
+	}
+	if len(posts.Triggers()) != 0 {
+		t.Fatalf("triggers left: %v", posts.Triggers())
+	}
+	if err := posts.DropTrigger("count_insert"); err == nil {
+		t.Fatal("dropping an unknown trigger succeeded")
+	}
+}
@@ -0,0 +1,428 @@
+package sqldb
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Tx groups record changes so they become visible together on Commit, or not at all.
+//
+// A transaction reads from the snapshot taken by Begin, with its own uncommitted
+// changes applied on top, so it never sees commits that happen while it runs.
+// Commit fails if another transaction committed a change to one of the same rows
+// first; the caller can retry the whole transaction. A statement that fails inside a
+// transaction leaves it as it was before the statement.
+type Tx struct {
+	db       *Database // nil for a write to a table outside any database
+	mu       sync.Mutex
+	snapshot uint64
+	writes   map[string]*writeSet // table name -> uncommitted changes
+	done     bool
+
+	// autocommit marks the single statement transactions Database and Table writes run
+	// in; their caller already holds the catalog lock and the tables' write locks
+	autocommit bool
+	depth      int // number of triggers the transaction runs inside, see nested
+}
+
+// Begin starts a transaction. It must be finished with Commit or Rollback, otherwise
+// the versions its snapshot can see are never vacuumed.
+func (db *Database) Begin() *Tx {
+	return &Tx{db: db, snapshot: db.tm.acquireSnapshot(), writes: make(map[string]*writeSet)}
+}
+
+// autocommit runs fn in a transaction of its own and commits it. Writers of t and of
+// every table linked to it by foreign keys are held off meanwhile, so the transaction
+// reads the latest commit and can't conflict.
+func (db *Database) autocommit(t *Table, fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.tables[t.Name] != t {
+		return fmt.Errorf("table %s is no longer part of the database", t.Name)
+	}
+
+	unlock := db.lockWriters(t)
+	defer unlock()
+	tx := &Tx{db: db, snapshot: db.tm.committed.Load(), writes: make(map[string]*writeSet), autocommit: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commitLocked()
+}
+
+// lockWriters locks the tables a write to t may touch: those linked to it by foreign
+// keys, or every table of the database once one of those has triggers, which can write
+// anywhere. The caller holds db.mu.
+func (db *Database) lockWriters(t *Table) func() {
+	group := db.fkGroup(t)
+	if !slices.ContainsFunc(group, (*Table).hasAnyTriggers) {
+		unlock := lockTables(group)
+		// AddTrigger takes the table's lock, so the group can't gain a trigger from here
+		// on, but it may have gained one since it was checked
+		if !slices.ContainsFunc(group, (*Table).hasAnyTriggers) {
+			return unlock
+		}
+		unlock()
+	}
+	return lockTables(slices.Collect(maps.Values(db.tables)))
+}
+
+// Commit publishes every change made in the transaction atomically.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.checkNotNested(); err != nil {
+		return err
+	}
+	if err := tx.finish(); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	db := tx.db
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var tables []*Table
+	for _, name := range sortedNames(tx.writes) {
+		ws := tx.writes[name]
+		if db.tables[name] != ws.table {
+			return fmt.Errorf("transaction conflict: table %s was dropped or altered", name)
+		}
+		tables = append(tables, ws.table)
+	}
+	unlock := lockTables(db.fkGroup(tables...))
+	defer unlock()
+	return tx.commitLocked()
+}
+
+// commitLocked validates and publishes the write sets. The caller holds the write
+// locks of every table involved.
+func (tx *Tx) commitLocked() error {
+	if err := tx.checkForeignKeysAtCommit(); err != nil {
+		return err
+	}
+	sets := make([]*writeSet, 0, len(tx.writes))
+	for _, name := range sortedNames(tx.writes) {
+		sets = append(sets, tx.writes[name])
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	// every table of a database shares its transaction manager
+	return sets[0].table.tm.commit(sets)
+}
+
+// Rollback discards every change made in the transaction.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.checkNotNested(); err != nil {
+		return err
+	}
+	if err := tx.finish(); err != nil {
+		return err
+	}
+	tx.writes = nil
+	return nil
+}
+
+func (tx *Tx) checkNotNested() error {
+	if tx.depth > 0 {
+		return fmt.Errorf("a trigger can't commit or roll back the transaction it runs in")
+	}
+	return nil
+}
+
+func (tx *Tx) finish() error {
+	if tx.done {
+		return fmt.Errorf("transaction has already been committed or rolled back")
+	}
+	tx.done = true
+	tx.db.tm.releaseSnapshot(tx.snapshot)
+	return nil
+}
+
+// statement runs one statement of the transaction, undoing its changes if it fails.
+func (tx *Tx) statement(fn func() error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("transaction has already been committed or rolled back")
+	}
+	if !tx.autocommit {
+		// keeps the tables the statement touches in the catalog until it is done
+		tx.db.mu.RLock()
+		defer tx.db.mu.RUnlock()
+	}
+
+	// a trigger's statement runs inside another one, which may still undo it
+	marks := make(map[*writeSet]int, len(tx.writes))
+	for _, ws := range tx.writes {
+		marks[ws] = len(ws.undo)
+	}
+	err := fn()
+	for _, ws := range tx.writes {
+		switch {
+		case err != nil:
+			ws.rollbackTo(marks[ws])
+		case tx.depth == 0:
+			ws.commitStatement()
+		}
+	}
+	return err
+}
+
+// table looks a table up. The caller must run inside tx.statement.
+func (tx *Tx) table(name string) (*Table, error) {
+	if ws, ok := tx.writes[name]; ok {
+		return ws.table, nil
+	}
+	if tx.db == nil {
+		return nil, tableNotFound(name)
+	}
+	table, ok := tx.db.tables[name]
+	if !ok {
+		return nil, tableNotFound(name)
+	}
+	return table, nil
+}
+
+// writeSet returns the transaction's write set for a table, creating it on first use.
+func (tx *Tx) writeSet(t *Table) *writeSet {
+	ws, ok := tx.writes[t.Name]
+	if !ok {
+		ws = newWriteSet(t)
+		tx.writes[t.Name] = ws
+	}
+	return ws
+}
+
+// insert adds records to t and returns the auto-increment value of the last one.
+func (tx *Tx) insert(t *Table, records []map[string]any) (int64, error) {
+	ws := tx.writeSet(t)
+	ids, err := ws.insert(tx.snapshot, records, tx.before(t, ChangeInsert))
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.checkParents(tx.snapshot, t, ids); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		change := &RowChange{Table: t.Name, Op: ChangeInsert, New: copyRow(ws.rows[id].data)}
+		if err := tx.fire(t, After, change); err != nil {
+			return 0, err
+		}
+	}
+	return ws.lastInsertID(ids), nil
+}
+
+func (tx *Tx) updateWhere(t *Table, pred Predicate, changes map[string]any) (int, error) {
+	ws := tx.writeSet(t)
+	before, err := ws.update(tx.snapshot, pred, changes, tx.before(t, ChangeUpdate))
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]int64, 0, len(before))
+	var removed []any
+	pk := t.PrimaryKey()
+	for _, ref := range before {
+		ids = append(ids, ref.id)
+		if pk != nil && !sameKey(ref.data[pk.Name], ws.rows[ref.id].data[pk.Name]) {
+			removed = append(removed, ref.data[pk.Name])
+		}
+	}
+	if err := tx.checkNotReferenced(tx.snapshot, t, removed); err != nil {
+		return 0, err
+	}
+	for colName := range changes {
+		if t.GetColumn(colName).Constraints.References != nil {
+			if err := tx.checkParents(tx.snapshot, t, ids); err != nil {
+				return 0, err
+			}
+			break
+		}
+	}
+	for _, ref := range before {
+		change := &RowChange{Table: t.Name, Op: ChangeUpdate, Old: copyRow(ref.data), New: copyRow(ws.rows[ref.id].data)}
+		if err := tx.fire(t, After, change); err != nil {
+			return 0, err
+		}
+	}
+	return len(before), nil
+}
+
+func (tx *Tx) deleteWhere(t *Table, pred Predicate) (int, error) {
+	deleted, err := tx.writeSet(t).delete(tx.snapshot, pred, tx.before(t, ChangeDelete))
+	if err != nil {
+		return 0, err
+	}
+	if pk := t.PrimaryKey(); pk != nil {
+		keys := make([]any, 0, len(deleted))
+		for _, ref := range deleted {
+			keys = append(keys, ref.data[pk.Name])
+		}
+		if err := tx.onParentsDeleted(t, keys); err != nil {
+			return 0, err
+		}
+	}
+	for _, ref := range deleted {
+		if err := tx.fire(t, After, &RowChange{Table: t.Name, Op: ChangeDelete, Old: copyRow(ref.data)}); err != nil {
+			return 0, err
+		}
+	}
+	return len(deleted), nil
+}
+
+func (tx *Tx) columns(tableName string) ([]*Column, error) {
+	var columns []*Column
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		columns = table.Columns
+		return nil
+	})
+	return columns, err
+}
+
+func (tx *Tx) insertRows(tableName string, records []map[string]any) (int64, error) {
+	var id int64
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		id, err = tx.insert(table, records)
+		return err
+	})
+	return id, err
+}
+
+func (tx *Tx) InsertRecord(tableName string, record map[string]any) error {
+	_, err := tx.insertRows(tableName, []map[string]any{record})
+	return err
+}
+
+// InsertReturningID is InsertRecord, also returning the value generated for the
+// table's AutoIncrement column.
+func (tx *Tx) InsertReturningID(tableName string, record map[string]any) (int64, error) {
+	return tx.insertRows(tableName, []map[string]any{record})
+}
+
+func (tx *Tx) GetRecords(tableName string, filter map[string]any) ([]map[string]any, error) {
+	return tx.GetRecordsWhere(tableName, FilterFromMap(filter))
+}
+
+func (tx *Tx) GetRecordsWhere(tableName string, pred Predicate) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := table.checkPredicate(pred); err != nil {
+			return err
+		}
+		rows = refsToRows(table.scan(tx.snapshot, pred, tx.writes[tableName]))
+		return nil
+	})
+	return rows, err
+}
+
+func (tx *Tx) Select(tableName string, opts QueryOptions) (*ResultSet, error) {
+	var rs *ResultSet
+	err := tx.statement(func() error {
+		var err error
+		rs, _, err = tx.query(tableName, opts, false)
+		return err
+	})
+	return rs, err
+}
+
+// query runs a read inside tx.statement.
+func (tx *Tx) query(tableName string, opts QueryOptions, explain bool) (*ResultSet, *PlanNode, error) {
+	lookup := func(name string) (source, error) {
+		table, err := tx.table(name)
+		if err != nil {
+			return source{}, err
+		}
+		return source{table: table, ws: tx.writes[name]}, nil
+	}
+	from, err := lookup(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	joined, err := resolveJoins(opts.Joins, lookup)
+	if err != nil {
+		return nil, nil, err
+	}
+	return runQuery(tx.snapshot, from, joined, opts, explain)
+}
+
+func (tx *Tx) explain(tableName string, opts QueryOptions) (*PlanNode, error) {
+	var plan *PlanNode
+	err := tx.statement(func() error {
+		var err error
+		_, plan, err = tx.query(tableName, opts, true)
+		return err
+	})
+	return plan, err
+}
+
+func (tx *Tx) GetByPrimaryKey(tableName string, key any) (map[string]any, error) {
+	var row map[string]any
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		row, err = table.getByPrimaryKey(tx.snapshot, key, tx.writes[tableName])
+		return err
+	})
+	return row, err
+}
+
+func (tx *Tx) UpdateRecords(tableName string, filter map[string]any, changes map[string]any) (int, error) {
+	return tx.UpdateRecordsWhere(tableName, FilterFromMap(filter), changes)
+}
+
+func (tx *Tx) UpdateRecordsWhere(tableName string, pred Predicate, changes map[string]any) (int, error) {
+	var n int
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := table.checkPredicate(pred); err != nil {
+			return err
+		}
+		n, err = tx.updateWhere(table, pred, changes)
+		return err
+	})
+	return n, err
+}
+
+func (tx *Tx) DeleteRecords(tableName string, filter map[string]any) (int, error) {
+	return tx.DeleteRecordsWhere(tableName, FilterFromMap(filter))
+}
+
+func (tx *Tx) DeleteRecordsWhere(tableName string, pred Predicate) (int, error) {
+	var n int
+	err := tx.statement(func() error {
+		table, err := tx.table(tableName)
+		if err != nil {
+			return err
+		}
+		if err := table.checkPredicate(pred); err != nil {
+			return err
+		}
+		n, err = tx.deleteWhere(table, pred)
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,112 @@
+package sqldb
+
+import "testing"
+
+func TestTxCommitIsAtomic(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	tx := db.Begin()
+	tx.InsertRecord("users", map[string]any{"id": 1, "name": "ada"})
+	tx.InsertRecord("users", map[string]any{"id": 2, "name": "linus"})
+	if n := rowCount(t, db, "users"); n != 0 {
+		t.Fatalf("%d uncommitted rows are visible outside the transaction", n)
+	}
+	// the transaction sees its own changes
+	if rows, _ := tx.GetRecords("users", nil); len(rows) != 2 {
+		t.Fatalf("transaction sees %d of its rows, want 2", len(rows))
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rowCount(t, db, "users"); n != 2 {
+		t.Fatalf("got %d rows after commit, want 2", n)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("committing twice succeeded")
+	}
+}
+
+func TestTxRollbackDiscardsChanges(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	db.InsertRecord("users", map[string]any{"id": 1, "name": "ada"})
+	tx := db.Begin()
+	tx.InsertRecord("users", map[string]any{"id": 2, "name": "linus"})
+	tx.UpdateRecords("users", map[string]any{"id": 1}, map[string]any{"name": "grace"})
+	tx.DeleteRecords("users", map[string]any{"id": 1})
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := db.GetRecords("users", nil)
+	if len(rows) != 1 || rows[0]["name"] != "ada" {
+		t.Fatalf("got %v after rollback, want only ada", rows)
+	}
+	if err := tx.InsertRecord("users", map[string]any{"id": 3, "name": "x"}); err == nil {
+		t.Fatal("writing to a rolled back transaction succeeded")
+	}
+}
+
+func TestTxFailedStatementIsUndoneAlone(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	tx := db.Begin()
+	tx.InsertRecord("users", map[string]any{"id": 1, "name": "ada"})
+	// a failed statement leaves the transaction as it was before it
+	if _, err := tx.UpdateRecords("users", nil, map[string]any{"name": "toolong"}); err == nil {
+		t.Fatal("update breaking max length succeeded")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	row, _ := db.GetByPrimaryKey("users", 1)
+	if row["name"] != "ada" {
+		t.Fatalf("got %v, want the row as inserted", row)
+	}
+}
+
+func TestTxSnapshotIsolationAndConflicts(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	db.InsertRecord("users", map[string]any{"id": 1, "name": "ada"})
+	first, second := db.Begin(), db.Begin()
+	defer second.Rollback()
+
+	if _, err := first.UpdateRecords("users", map[string]any{"id": 1}, map[string]any{"name": "grace"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// second still reads the snapshot taken when it began
+	row, _ := second.GetByPrimaryKey("users", 1)
+	if row["name"] != "ada" {
+		t.Fatalf("second transaction sees %v, want its snapshot", row["name"])
+	}
+	if _, err := second.UpdateRecords("users", map[string]any{"id": 1}, map[string]any{"name": "linus"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Commit(); err == nil {
+		t.Fatal("committing a change to a row changed since the snapshot succeeded")
+	}
+	row, _ = db.GetByPrimaryKey("users", 1)
+	if row["name"] != "grace" {
+		t.Fatalf("got %v, want the first commit to win", row["name"])
+	}
+}
+
+func TestTxUniqueConflict(t *testing.T) {
+	db := NewDatabase()
+	createTable(t, db, "users", usersColumns())
+	first, second := db.Begin(), db.Begin()
+	first.InsertRecord("users", map[string]any{"id": 1, "name": "ada"})
+	second.InsertRecord("users", map[string]any{"id": 1, "name": "linus"})
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Commit(); err == nil {
+		t.Fatal("two transactions inserting the same key both committed")
+	}
+	if n := rowCount(t, db, "users"); n != 1 {
+		t.Fatalf("got %d rows, want 1", n)
+	}
+}
@@ -0,0 +1,757 @@
+package sqldb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when the write-ahead log is flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log before every write returns.
+	SyncAlways SyncPolicy = iota
+	// SyncBatch fsyncs the log in the background every SyncInterval; a crash of the
+	// machine can lose the writes of the last interval.
+	SyncBatch
+	// SyncNone leaves flushing to the operating system.
+	SyncNone
+)
+
+type Options struct {
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+}
+
+// WithSync sets the fsync policy of the write-ahead log. The default is SyncAlways.
+func WithSync(policy SyncPolicy) func(*Options) {
+	return func(o *Options) {
+		o.Sync = policy
+	}
+}
+
+// WithSyncInterval sets how often SyncBatch flushes the log. The default is 100ms.
+func WithSyncInterval(interval time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.SyncInterval = interval
+	}
+}
+
+const snapshotName = "snapshot.json"
+
+// walPath names the log files of a directory by generation; Compact starts a new one.
+func walPath(dir string, gen uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("wal-%06d.log", gen))
+}
+
+// Open opens the database stored in dir, creating the directory if needed. Every
+// schema change and commit is appended to a write-ahead log in dir before it takes
+// effect, and the log is replayed here, on top of the snapshot left by the last
+// Compact, to rebuild the tables. A record cut short by a crash at the end of the
+// log is discarded; a corrupt record anywhere else makes Open fail, leaving the log
+// untouched.
+func Open(dir string, options ...func(*Options)) (*Database, error) {
+	opts := Options{Sync: SyncAlways, SyncInterval: 100 * time.Millisecond}
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	db := NewDatabase()
+	gen := uint64(1)
+	if f, err := os.Open(filepath.Join(dir, snapshotName)); err == nil {
+		gen, err = db.loadSnapshot(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	gens, err := walGenerations(dir)
+	if err != nil {
+		return nil, err
+	}
+	var end int64
+	for _, g := range gens {
+		if g < gen {
+			// already in the snapshot, left behind by a crash during Compact
+			os.Remove(walPath(dir, g))
+			continue
+		}
+		if end, err = db.replayFile(walPath(dir, g)); err != nil {
+			return nil, err
+		}
+		gen = g
+	}
+
+	f, err := os.OpenFile(walPath(dir, gen), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// drop a torn tail so new records follow the last complete one
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := syncDir(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	db.tm.log = newWAL(dir, gen, f, opts)
+	return db, nil
+}
+
+// Close flushes and closes the write-ahead log. It is a no-op for in-memory databases.
+func (db *Database) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.tm.log == nil {
+		return nil
+	}
+	return db.tm.log.close()
+}
+
+// Compact writes the state of the database to a snapshot in its directory and starts
+// a new, empty write-ahead log, so the next Open only replays the changes made since.
+// Writers wait while it runs.
+func (db *Database) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	w := db.tm.log
+	if w == nil {
+		return fmt.Errorf("database has no write-ahead log to compact")
+	}
+	// every commit, from Database and Table methods (see autocommit) as well as from
+	// Tx.Commit, holds db.mu shared, so the lock above already holds writers off;
+	// commitMu, under which commits append to the log, is taken as well so the log
+	// can't be appended to while it is rotated, whoever commits
+	db.tm.commitMu.Lock()
+	defer db.tm.commitMu.Unlock()
+
+	next := w.gen + 1
+	f, err := os.OpenFile(walPath(w.dir, next), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	// a crash before the rename leaves the old snapshot and both logs, which replay
+	// to the same state
+	tmp := filepath.Join(w.dir, snapshotName+".tmp")
+	if err := db.writeSnapshotFile(tmp, next); err != nil {
+		f.Close()
+		os.Remove(walPath(w.dir, next))
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(w.dir, snapshotName)); err != nil {
+		f.Close()
+		os.Remove(walPath(w.dir, next))
+		return err
+	}
+	// the snapshot now covers the old log, so from here on appends must go to the new one
+	prev := w.gen
+	if err := w.rotate(f, next); err != nil {
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+	return os.Remove(walPath(w.dir, prev))
+}
+
+func (db *Database) writeSnapshotFile(path string, gen uint64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := db.saveSnapshot(f, gen); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// walGenerations lists the generations of the log files in dir in ascending order.
+func walGenerations(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var gens []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "wal-")
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".log"); !ok {
+			continue
+		}
+		if gen, err := strconv.ParseUint(name, 10, 64); err == nil {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+	return gens, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// wal appends length-prefixed, checksummed records to the log file:
+//
+//	length uint32 | crc32 uint32 | JSON encoded walRecord
+type wal struct {
+	dir string
+	gen uint64
+
+	mu     sync.Mutex
+	f      *os.File
+	policy SyncPolicy
+	dirty  bool
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newWAL(dir string, gen uint64, f *os.File, opts Options) *wal {
+	w := &wal{dir: dir, gen: gen, f: f, policy: opts.Sync}
+	if opts.Sync == SyncBatch {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.syncLoop(opts.SyncInterval)
+	}
+	return w
+}
+
+func (w *wal) append(rec *walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[8:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("database is closed")
+	}
+	if _, err := w.f.Write(buf); err != nil {
+		return fmt.Errorf("write-ahead log: %v", err)
+	}
+	if w.policy == SyncAlways {
+		if err := w.f.Sync(); err != nil {
+			return fmt.Errorf("write-ahead log: %v", err)
+		}
+	}
+	w.dirty = true
+	return nil
+}
+
+func (w *wal) syncLoop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.dirty && !w.closed {
+				w.f.Sync()
+				w.dirty = false
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// rotate switches appends over to f, the log of generation gen.
+func (w *wal) rotate(f *os.File, gen uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	old := w.f
+	w.f, w.gen, w.dirty = f, gen, false
+	if err := old.Sync(); err != nil {
+		old.Close()
+		return err
+	}
+	return old.Close()
+}
+
+func (w *wal) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+		w.stop = nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// readRecord reads the next record. It returns io.EOF at the end of the log and
+// io.ErrUnexpectedEOF for a torn final record: one cut short by the end of the log, or
+// failing its checksum with nothing after it. A record failing its checksum in the
+// middle of the log is corruption, not a crash, and is an error.
+func readRecord(r *bufio.Reader) (*walRecord, int64, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		if _, err := r.Peek(1); err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, fmt.Errorf("write-ahead log: record fails its checksum")
+	}
+	rec := &walRecord{}
+	if err := json.Unmarshal(payload, rec); err != nil {
+		return nil, 0, fmt.Errorf("write-ahead log: %v", err)
+	}
+	return rec, int64(len(header) + len(payload)), nil
+}
+
+type walOp string
+
+const (
+	walCreateTable walOp = "create_table"
+	walDropTable   walOp = "drop_table"
+	walCreateIndex walOp = "create_index"
+	walDropIndex   walOp = "drop_index"
+	walAlterTable  walOp = "alter_table"
+	walCommit      walOp = "commit"
+)
+
+type walRecord struct {
+	Op      walOp       `json:"op"`
+	Table   string      `json:"table,omitempty"`
+	Columns []columnDef `json:"columns,omitempty"`
+	Column  string      `json:"column,omitempty"`
+	Kind    IndexKind   `json:"kind,omitempty"`
+	Changes []walChange `json:"changes,omitempty"`
+
+	Sources  map[string]string          `json:"sources,omitempty"`
+	Defaults map[string]json.RawMessage `json:"defaults,omitempty"`
+}
+
+// walChange is the new state of one row; a nil Row deletes it.
+type walChange struct {
+	Table string                     `json:"table"`
+	ID    int64                      `json:"id"`
+	Row   map[string]json.RawMessage `json:"row,omitempty"`
+}
+
+// logRecord appends rec to the write-ahead log, if the database has one.
+func (tm *txManager) logRecord(rec *walRecord) error {
+	if tm.log == nil {
+		return nil
+	}
+	return tm.log.append(rec)
+}
+
+// checkLoggable rejects schemas that can't be written to the log.
+func (tm *txManager) checkLoggable(columns []*Column, checks []TableCheck) error {
+	if tm.log == nil {
+		return nil
+	}
+	if err := checkSerializable(columns, checks); err != nil {
+		return fmt.Errorf("%v, which only in-memory databases support", err)
+	}
+	return nil
+}
+
+// checkSerializable rejects schemas holding Go functions, which can't be written to
+// the log or a snapshot.
+func checkSerializable(columns []*Column, checks []TableCheck) error {
+	for _, col := range columns {
+		cc := col.Constraints
+		if cc.DefaultFunc != nil || cc.Computed != nil || len(cc.Checks) > 0 {
+			return fmt.Errorf("column %s has a DefaultFunc, Computed or Check", col.Name)
+		}
+	}
+	if len(checks) > 0 {
+		return fmt.Errorf("the table has checks")
+	}
+	return nil
+}
+
+// commitRecord encodes the write sets as a single commit record, or returns nil if
+// they change nothing.
+func commitRecord(sets []*writeSet) (*walRecord, error) {
+	rec := &walRecord{Op: walCommit}
+	for _, ws := range sets {
+		for _, id := range sortedIDs(ws.rows) {
+			change := walChange{Table: ws.table.Name, ID: id}
+			if data := ws.rows[id].data; data != nil {
+				row, err := encodeRow(data)
+				if err != nil {
+					return nil, err
+				}
+				change.Row = row
+			}
+			rec.Changes = append(rec.Changes, change)
+		}
+	}
+	if len(rec.Changes) == 0 {
+		return nil, nil
+	}
+	return rec, nil
+}
+
+// replayFile applies every complete record in the log file at path and returns the
+// offset just past the last one.
+func (db *Database) replayFile(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return db.replay(bufio.NewReader(f))
+}
+
+func (db *Database) replay(r *bufio.Reader) (int64, error) {
+	// current version of every row, so changes can find the version they replace
+	current := make(map[*Table]map[int64]*rowVersion)
+	var offset int64
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return offset, nil
+		}
+		if err != nil {
+			// the log is left as it is, so the records after offset can be recovered
+			return 0, fmt.Errorf("%v at offset %d", err, offset)
+		}
+		if err := db.replayRecord(rec, current); err != nil {
+			return 0, fmt.Errorf("write-ahead log at offset %d: %v", offset, err)
+		}
+		offset += n
+	}
+}
+
+func (db *Database) replayRecord(rec *walRecord, current map[*Table]map[int64]*rowVersion) error {
+	switch rec.Op {
+	case walCreateTable:
+		columns, err := decodeColumns(rec.Columns)
+		if err != nil {
+			return err
+		}
+		if err := db.checkNewTable(rec.Table, columns, nil); err != nil {
+			return err
+		}
+		db.createTable(rec.Table, columns, nil)
+		return nil
+	case walDropTable:
+		if _, ok := db.tables[rec.Table]; !ok {
+			return tableNotFound(rec.Table)
+		}
+		delete(db.tables, rec.Table)
+		return nil
+	case walCreateIndex:
+		return db.writeTable(rec.Table, func(table *Table) error {
+			return table.CreateIndex(rec.Column, rec.Kind)
+		})
+	case walDropIndex:
+		return db.writeTable(rec.Table, func(table *Table) error {
+			return table.DropIndex(rec.Column)
+		})
+	case walAlterTable:
+		return db.replayAlter(rec)
+	case walCommit:
+		sets := make(map[string]*writeSet)
+		var names []string
+		for _, change := range rec.Changes {
+			ws, ok := sets[change.Table]
+			if !ok {
+				table, exists := db.tables[change.Table]
+				if !exists {
+					return tableNotFound(change.Table)
+				}
+				ws = newWriteSet(table)
+				sets[change.Table] = ws
+				names = append(names, change.Table)
+			}
+			t := ws.table
+			if current[t] == nil {
+				// the table may hold rows loaded from a snapshot
+				current[t] = make(map[int64]*rowVersion)
+				for _, v := range *t.versions.Load() {
+					if v.xmax.Load() == 0 {
+						current[t][v.id] = v
+					}
+				}
+			}
+			p := &pendingRow{base: current[t][change.ID]}
+			if change.Row != nil {
+				data, err := decodeRow(t.Columns, change.Row)
+				if err != nil {
+					return err
+				}
+				p.data = data
+			}
+			ws.rows[change.ID] = p
+			if change.ID > t.nextID.Load() {
+				t.nextID.Store(change.ID)
+			}
+		}
+
+		// publish directly: the log is replayed alone and every record was valid when written
+		seq := db.tm.committed.Load() + 1
+		for _, name := range names {
+			ws := sets[name]
+			for _, v := range ws.apply(seq) {
+				current[ws.table][v.id] = v
+			}
+			for id, p := range ws.rows {
+				if p.data == nil {
+					delete(current[ws.table], id)
+				}
+			}
+		}
+		db.tm.committed.Store(seq)
+		for _, name := range names {
+			sets[name].table.maybeVacuum()
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown record %q", rec.Op)
+}
+
+// columnDef is the logged form of a column.
+type columnDef struct {
+	Name       string            `json:"name"`
+	Type       ColumnType        `json:"type"`
+	Required   bool              `json:"required,omitempty"`
+	PrimaryKey bool              `json:"primary_key,omitempty"`
+	Unique     bool              `json:"unique,omitempty"`
+	MaxLength  *int              `json:"max_length,omitempty"`
+	MinLength  *int              `json:"min_length,omitempty"`
+	MinValue   *int              `json:"min_value,omitempty"`
+	MaxValue   *int              `json:"max_value,omitempty"`
+	Pattern    string            `json:"pattern,omitempty"`
+	Enum       []json.RawMessage `json:"enum,omitempty"`
+	References string            `json:"references,omitempty"`
+	OnDelete   ReferenceAction   `json:"on_delete,omitempty"`
+
+	AutoIncrement bool            `json:"auto_increment,omitempty"`
+	Default       json.RawMessage `json:"default,omitempty"`
+}
+
+func encodeColumns(columns []*Column) ([]columnDef, error) {
+	defs := make([]columnDef, 0, len(columns))
+	for _, col := range columns {
+		cc := col.Constraints
+		def := columnDef{
+			Name:       col.Name,
+			Type:       col.Type,
+			Required:   cc.Required,
+			PrimaryKey: cc.PrimaryKey,
+			Unique:     cc.Unique,
+			MaxLength:  cc.MaxLength,
+			MinLength:  cc.MinLength,
+			MinValue:   cc.MinValue,
+			MaxValue:   cc.MaxValue,
+		}
+		if cc.Pattern != nil {
+			def.Pattern = cc.Pattern.String()
+		}
+		if cc.References != nil {
+			def.References = cc.References.Table
+			def.OnDelete = cc.References.OnDelete
+		}
+		def.AutoIncrement = cc.AutoIncrement
+		if cc.Default != nil {
+			val, err := col.Coerce(cc.Default)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", col.Name, err)
+			}
+			if def.Default, err = json.Marshal(val); err != nil {
+				return nil, fmt.Errorf("column %s: %v", col.Name, err)
+			}
+		}
+		for _, val := range cc.Enum {
+			raw, err := json.Marshal(val)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", col.Name, err)
+			}
+			def.Enum = append(def.Enum, raw)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func decodeColumns(defs []columnDef) ([]*Column, error) {
+	columns := make([]*Column, 0, len(defs))
+	for _, def := range defs {
+		col := &Column{
+			Name: def.Name,
+			Type: def.Type,
+			Constraints: ColumnConstraint{
+				Required:   def.Required,
+				PrimaryKey: def.PrimaryKey,
+				Unique:     def.Unique,
+				MaxLength:  def.MaxLength,
+				MinLength:  def.MinLength,
+				MinValue:   def.MinValue,
+				MaxValue:   def.MaxValue,
+
+				AutoIncrement: def.AutoIncrement,
+			},
+		}
+		if def.PrimaryKey {
+			PrimaryKey()(&col.Constraints)
+		}
+		if def.References != "" {
+			onDelete := def.OnDelete
+			if onDelete == "" {
+				onDelete = Restrict
+			}
+			References(def.References, onDelete)(&col.Constraints)
+		}
+		if def.Pattern != "" {
+			re, err := regexp.Compile(def.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			col.Constraints.Pattern = re
+		}
+		if def.Default != nil {
+			val, err := decodeValue(col, def.Default)
+			if err != nil {
+				return nil, err
+			}
+			col.Constraints.Default = val
+		}
+		for _, raw := range def.Enum {
+			val, err := decodeValue(col, raw)
+			if err != nil {
+				return nil, err
+			}
+			col.Constraints.Enum = append(col.Constraints.Enum, val)
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+func encodeRow(row map[string]any) (map[string]json.RawMessage, error) {
+	encoded := make(map[string]json.RawMessage, len(row))
+	for col, val := range row {
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col, err)
+		}
+		encoded[col] = raw
+	}
+	return encoded, nil
+}
+
+func decodeRow(columns []*Column, encoded map[string]json.RawMessage) (map[string]any, error) {
+	row := make(map[string]any, len(encoded))
+	for name, raw := range encoded {
+		col := findColumn(columns, name)
+		if col == nil {
+			return nil, unknownColumn(name)
+		}
+		val, err := decodeValue(col, raw)
+		if err != nil {
+			return nil, err
+		}
+		row[name] = val
+	}
+	return row, nil
+}
+
+// decodeValue turns the JSON encoding of a stored value back into the value, using the
+// column type to tell e.g. bytes from strings.
+func decodeValue(col *Column, raw json.RawMessage) (any, error) {
+	if bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	var val any
+	var err error
+	switch col.Type {
+	case TypeString:
+		var s string
+		err = json.Unmarshal(raw, &s)
+		val = s
+	case TypeInt:
+		var n int64
+		n, err = strconv.ParseInt(string(raw), 10, 64)
+		val = n
+	case TypeFloat:
+		var f float64
+		err = json.Unmarshal(raw, &f)
+		val = f
+	case TypeBool:
+		var b bool
+		err = json.Unmarshal(raw, &b)
+		val = b
+	case TypeTimestamp:
+		var text string
+		if err = json.Unmarshal(raw, &text); err == nil {
+			val, err = convertToTime(text)
+		}
+	case TypeBytes:
+		var b []byte
+		err = json.Unmarshal(raw, &b)
+		val = b
+	case TypeJSON:
+		val = json.RawMessage(bytes.Clone(raw))
+	default:
+		err = fmt.Errorf("unknown type %s", col.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %v", col.Name, err)
+	}
+	return val, nil
+}
@@ -0,0 +1,170 @@
+package sqldb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openWithRows opens a database in a new directory, adds a table with n rows and
+// closes it again, returning the directory.
+func openWithRows(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for i := 1; i <= n; i++ {
+		rows = append(rows, map[string]any{"id": i})
+	}
+	createTable(t, db, "users", []*Column{NewColumn("id", TypeInt, PrimaryKey())}, rows...)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOpenDiscardsTornTail(t *testing.T) {
+	dir := openWithRows(t, 3)
+	path := walPath(dir, 1)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a header promising more payload than the crash left behind
+	torn := make([]byte, 8, 12)
+	binary.LittleEndian.PutUint32(torn[0:4], 100)
+	f.Write(append(torn, `{"op`...))
+	f.Close()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open with a torn tail: %v", err)
+	}
+	if n := rowCount(t, db, "users"); n != 3 {
+		t.Fatalf("got %d rows, want 3", n)
+	}
+	// new records must follow the last complete one, not the torn bytes
+	if err := db.InsertRecord("users", map[string]any{"id": 4}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n := rowCount(t, db, "users"); n != 4 {
+		t.Fatalf("got %d rows after reopening, want 4", n)
+	}
+}
+
+func TestOpenRejectsCorruptionInTheMiddle(t *testing.T) {
+	dir := openWithRows(t, 3)
+	path := walPath(dir, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip the last payload byte of the second record, the first commit
+	first := 8 + int(binary.LittleEndian.Uint32(data[0:4]))
+	second := first + 8 + int(binary.LittleEndian.Uint32(data[first:first+4]))
+	data[second-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open(dir)
+	if err == nil || !strings.Contains(err.Error(), "offset") {
+		t.Fatalf("Open with a corrupt record = %v, want an error giving the offset", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(data) {
+		t.Fatalf("log was truncated from %d to %d bytes", len(data), len(after))
+	}
+}
+
+func TestOpenDiscardsCorruptFinalRecord(t *testing.T) {
+	dir := openWithRows(t, 3)
+	path := walPath(dir, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open with a corrupt final record: %v", err)
+	}
+	defer db.Close()
+	if n := rowCount(t, db, "users"); n != 2 {
+		t.Fatalf("got %d rows, want 2", n)
+	}
+}
+
+func TestReplayKeepsIntsAsInt64(t *testing.T) {
+	dir := openWithRows(t, 2)
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.GetRecords("users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if got := fmt.Sprintf("%T", row["id"]); got != "int64" {
+			t.Fatalf("replayed id is a %s, want int64", got)
+		}
+	}
+}
+
+func TestReplayUnderSyncPolicies(t *testing.T) {
+	for name, policy := range map[string]SyncPolicy{"batch": SyncBatch, "none": SyncNone} {
+		dir := t.TempDir()
+		db, err := Open(dir, WithSync(policy), WithSyncInterval(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		mustExec(t, db, "CREATE TABLE users (id INT PRIMARY KEY)")
+		for i := range 20 {
+			mustExec(t, db, "INSERT INTO users (id) VALUES (?)", i)
+		}
+		if policy == SyncBatch {
+			// wait for the background sync to catch up
+			for synced := false; !synced; time.Sleep(time.Millisecond) {
+				db.tm.log.mu.Lock()
+				synced = !db.tm.log.dirty
+				db.tm.log.mu.Unlock()
+			}
+		}
+
+		// the log as it is while the database is still open, as a crash would leave it
+		crashed := t.TempDir()
+		if err := os.CopyFS(crashed, os.DirFS(dir)); err != nil {
+			t.Fatal(err)
+		}
+		replayed, err := Open(crashed)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n := rowCount(t, replayed, "users"); n != 20 {
+			t.Errorf("%s: replayed %d rows, want 20", name, n)
+		}
+		replayed.Close()
+	}
+}
@@ -0,0 +1,131 @@
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+// Error is an error the server returned for a request. errors.Is and errors.As see
+// through it to the sqldb errors it came from: the sentinel errors such as
+// sqldb.ErrTableNotFound, and every *sqldb.ConstraintViolation.
+type Error struct {
+	Message string
+	errs    []error
+}
+
+func (e *Error) Error() string   { return e.Message }
+func (e *Error) Unwrap() []error { return e.errs }
+
+// Client is one session with a Server. It is safe for concurrent use, but its
+// requests share the session and so its transaction; open one client per
+// independent unit of work.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	err  error // set once the connection is unusable
+}
+
+// Dial connects to a server listening on network ("tcp" or "unix") and address.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = errors.New("wire: client closed")
+	}
+	return c.conn.Close()
+}
+
+// Exec runs statements, see sqldb.Database.Exec, inside the session's transaction if
+// one is open.
+func (c *Client) Exec(query string, args ...any) (sqldb.Result, error) {
+	resp, err := c.call(opExec, query, args)
+	if err != nil {
+		return sqldb.Result{}, err
+	}
+	return sqldb.Result{RowsAffected: resp.RowsAffected, LastInsertID: resp.LastInsertID}, nil
+}
+
+// Query runs a SELECT statement, see sqldb.Database.Query, inside the session's
+// transaction if one is open.
+func (c *Client) Query(query string, args ...any) (*sqldb.ResultSet, error) {
+	resp, err := c.call(opQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	rs := &sqldb.ResultSet{Columns: resp.Columns, Cursor: resp.Cursor}
+	for _, encoded := range resp.Rows {
+		if len(encoded) != len(resp.Columns) {
+			return nil, fmt.Errorf("wire: row has %d values for %d columns", len(encoded), len(resp.Columns))
+		}
+		vals, err := decodeValues(encoded)
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(vals))
+		for i, col := range resp.Columns {
+			row[col] = vals[i]
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+	return rs, nil
+}
+
+// Begin opens a transaction for the session's following statements.
+func (c *Client) Begin() error {
+	_, err := c.call(opBegin, "", nil)
+	return err
+}
+
+func (c *Client) Commit() error {
+	_, err := c.call(opCommit, "", nil)
+	return err
+}
+
+func (c *Client) Rollback() error {
+	_, err := c.call(opRollback, "", nil)
+	return err
+}
+
+func (c *Client) call(op op, query string, args []any) (*response, error) {
+	encoded, err := encodeValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	// a failed write or read leaves the stream out of step, so the client gives up,
+	// unless the request was too large to be written at all
+	if err := writeFrame(c.conn, &request{Op: op, Query: query, Args: encoded}); err != nil {
+		if !errors.Is(err, ErrTooLarge) {
+			c.err = err
+		}
+		return nil, err
+	}
+	var resp response
+	if err := readFrame(c.r, &resp); err != nil {
+		c.err = err
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, responseError(&resp)
+	}
+	return &resp, nil
+}
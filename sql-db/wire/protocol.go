@@ -0,0 +1,264 @@
+// Package wire serves a sqldb.Database over a TCP or Unix socket and provides the
+// matching client, so several processes can share one database.
+//
+// Every message is a frame: a 4 byte big-endian length followed by that many bytes
+// of JSON. The client sends a request and reads exactly one response; each
+// connection is a session with at most one open transaction.
+package wire
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+// maxFrame bounds the size of a message either side accepts; a variable so tests can
+// lower it.
+var maxFrame = 64 << 20
+
+// ErrTooLarge is returned for a request or response above the frame size limit. The
+// message isn't sent, so the session goes on.
+var ErrTooLarge = errors.New("wire: message too large")
+
+type op string
+
+const (
+	opExec     op = "exec"
+	opQuery    op = "query"
+	opBegin    op = "begin"
+	opCommit   op = "commit"
+	opRollback op = "rollback"
+)
+
+type request struct {
+	Op    op      `json:"op"`
+	Query string  `json:"query,omitempty"`
+	Args  []value `json:"args,omitempty"`
+}
+
+type response struct {
+	Error        string      `json:"error,omitempty"`
+	Codes        []errorCode `json:"codes,omitempty"` // the sentinel errors Error wraps
+	Violations   []violation `json:"violations,omitempty"`
+	RowsAffected int         `json:"rows_affected,omitempty"`
+	LastInsertID int64       `json:"last_insert_id,omitempty"`
+	Columns      []string    `json:"columns,omitempty"`
+	Rows         [][]value   `json:"rows,omitempty"`
+	Cursor       string      `json:"cursor,omitempty"`
+}
+
+type errorCode string
+
+// codes name the sentinel errors a response can carry.
+var codes = []struct {
+	code errorCode
+	err  error
+}{
+	{"table_not_found", sqldb.ErrTableNotFound},
+	{"table_exists", sqldb.ErrTableExists},
+	{"unknown_column", sqldb.ErrUnknownColumn},
+	{"too_large", ErrTooLarge},
+}
+
+// violation is a sqldb.ConstraintViolation on the wire.
+type violation struct {
+	Table      string `json:"table,omitempty"`
+	Column     string `json:"column,omitempty"`
+	Constraint string `json:"constraint"`
+	Name       string `json:"name,omitempty"`
+	Value      value  `json:"value"`
+	Detail     string `json:"detail"`
+}
+
+// errorResponse describes err, with the sentinel errors and every constraint
+// violation it wraps, so the client can rebuild an error errors.Is and errors.As
+// work on.
+func errorResponse(err error) *response {
+	resp := &response{Error: err.Error()}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			resp.Codes = append(resp.Codes, c.code)
+		}
+	}
+	for _, v := range violations(err) {
+		val, encodeErr := encodeValue(v.Value)
+		if encodeErr != nil {
+			val = value{Type: "null"}
+		}
+		resp.Violations = append(resp.Violations, violation{Table: v.Table, Column: v.Column,
+			Constraint: v.Constraint, Name: v.Name, Value: val, Detail: v.Detail})
+	}
+	return resp
+}
+
+// violations lists every *sqldb.ConstraintViolation in the tree of err, depth first,
+// where errors.As would only find the first.
+func violations(err error) []*sqldb.ConstraintViolation {
+	if v, ok := err.(*sqldb.ConstraintViolation); ok {
+		return []*sqldb.ConstraintViolation{v}
+	}
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		return violations(x.Unwrap())
+	case interface{ Unwrap() []error }:
+		var all []*sqldb.ConstraintViolation
+		for _, err := range x.Unwrap() {
+			all = append(all, violations(err)...)
+		}
+		return all
+	}
+	return nil
+}
+
+// responseError rebuilds the error of a response.
+func responseError(resp *response) error {
+	e := &Error{Message: resp.Error}
+	for _, code := range resp.Codes {
+		for _, c := range codes {
+			if c.code == code {
+				e.errs = append(e.errs, c.err)
+			}
+		}
+	}
+	for _, v := range resp.Violations {
+		val, _ := decodeValue(v.Value)
+		e.errs = append(e.errs, &sqldb.ConstraintViolation{Table: v.Table, Column: v.Column,
+			Constraint: v.Constraint, Name: v.Name, Value: val, Detail: v.Detail})
+	}
+	return e
+}
+
+// value carries a Go value with its type, which plain JSON would lose.
+type value struct {
+	Type string          `json:"t"`
+	V    json.RawMessage `json:"v,omitempty"`
+}
+
+func encodeValue(val any) (value, error) {
+	var typ string
+	var v any = val
+	switch x := val.(type) {
+	case nil:
+		return value{Type: "null"}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		typ = "int"
+	case uint64:
+		if x > math.MaxInt64 {
+			return value{}, fmt.Errorf("%d is out of range for int", x)
+		}
+		typ = "int"
+	case float32, float64:
+		typ = "float"
+	case bool:
+		typ = "bool"
+	case string:
+		typ = "string"
+	case time.Time:
+		typ, v = "time", x.Format(time.RFC3339Nano)
+	case []byte:
+		typ, v = "bytes", base64.StdEncoding.EncodeToString(x)
+	case json.RawMessage:
+		typ, v = "json", string(x)
+	default:
+		return value{}, fmt.Errorf("unsupported value type %T", val)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return value{}, err
+	}
+	return value{Type: typ, V: raw}, nil
+}
+
+func decodeValue(v value) (any, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "int":
+		var n int64
+		err := json.Unmarshal(v.V, &n)
+		return n, err
+	case "float":
+		var f float64
+		err := json.Unmarshal(v.V, &f)
+		return f, err
+	case "bool":
+		var b bool
+		err := json.Unmarshal(v.V, &b)
+		return b, err
+	}
+	var s string
+	if err := json.Unmarshal(v.V, &s); err != nil {
+		return nil, err
+	}
+	switch v.Type {
+	case "string":
+		return s, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, s)
+	case "bytes":
+		return base64.StdEncoding.DecodeString(s)
+	case "json":
+		return json.RawMessage(s), nil
+	}
+	return nil, fmt.Errorf("unknown value type %s", v.Type)
+}
+
+func encodeValues(vals []any) ([]value, error) {
+	encoded := make([]value, len(vals))
+	for i, val := range vals {
+		var err error
+		if encoded[i], err = encodeValue(val); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+func decodeValues(vals []value) ([]any, error) {
+	decoded := make([]any, len(vals))
+	for i, v := range vals {
+		var err error
+		if decoded[i], err = decodeValue(v); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+func writeFrame(w io.Writer, msg any) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxFrame {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d", ErrTooLarge, len(payload), maxFrame)
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err = w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader, msg any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if int64(size) > int64(maxFrame) {
+		return fmt.Errorf("message of %d bytes exceeds the limit of %d", size, maxFrame)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, msg)
+}
@@ -0,0 +1,200 @@
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("wire: server closed")
+
+// Server serves a database to any number of concurrent sessions.
+type Server struct {
+	db *sqldb.Database
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(db *sqldb.Database) *Server {
+	return &Server{db: db, listeners: make(map[net.Listener]bool), conns: make(map[net.Conn]bool)}
+}
+
+// ListenAndServe listens on network ("tcp" or "unix") and address and serves until
+// Close is called.
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts sessions on l until Close is called. It always returns a non-nil
+// error.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return ErrServerClosed
+		}
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(c)
+	}
+}
+
+// Close stops the listeners, ends every session, rolling back open transactions, and
+// waits for them to finish. It doesn't close the database.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serveConn runs one session: requests are handled in order, and a transaction left
+// open when the connection ends is rolled back.
+func (s *Server) serveConn(c net.Conn) {
+	sess := &session{db: s.db}
+	defer func() {
+		if sess.tx != nil {
+			sess.tx.Rollback()
+		}
+		c.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		var req request
+		if err := readFrame(r, &req); err != nil {
+			return
+		}
+		err := writeFrame(c, sess.handle(&req))
+		if errors.Is(err, ErrTooLarge) {
+			// nothing was written, so the session can go on
+			err = writeFrame(c, errorResponse(err))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type session struct {
+	db *sqldb.Database
+	tx *sqldb.Tx // open transaction, nil outside Begin ... Commit/Rollback
+}
+
+func (sess *session) handle(req *request) *response {
+	resp, err := sess.run(req)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return resp
+}
+
+func (sess *session) run(req *request) (*response, error) {
+	switch req.Op {
+	case opBegin:
+		if sess.tx != nil {
+			return nil, fmt.Errorf("a transaction is already open")
+		}
+		sess.tx = sess.db.Begin()
+		return &response{}, nil
+	case opCommit, opRollback:
+		if sess.tx == nil {
+			return nil, fmt.Errorf("no transaction is open")
+		}
+		tx := sess.tx
+		sess.tx = nil
+		if req.Op == opCommit {
+			return &response{}, tx.Commit()
+		}
+		return &response{}, tx.Rollback()
+	}
+
+	args, err := decodeValues(req.Args)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Op {
+	case opExec:
+		var res sqldb.Result
+		if sess.tx != nil {
+			res, err = sess.tx.Exec(req.Query, args...)
+		} else {
+			res, err = sess.db.Exec(req.Query, args...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &response{RowsAffected: res.RowsAffected, LastInsertID: res.LastInsertID}, nil
+	case opQuery:
+		var rs *sqldb.ResultSet
+		if sess.tx != nil {
+			rs, err = sess.tx.Query(req.Query, args...)
+		} else {
+			rs, err = sess.db.Query(req.Query, args...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp := &response{Columns: rs.Columns, Cursor: rs.Cursor}
+		for _, row := range rs.Rows {
+			vals := make([]any, len(rs.Columns))
+			for i, col := range rs.Columns {
+				vals[i] = row[col]
+			}
+			encoded, err := encodeValues(vals)
+			if err != nil {
+				return nil, err
+			}
+			resp.Rows = append(resp.Rows, encoded)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", req.Op)
+}
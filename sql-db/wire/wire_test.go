@@ -0,0 +1,211 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sqldb "github.com/avalokitasharma/lld/sql-db"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC)
+	tests := []struct {
+		in, want any
+	}{
+		{nil, nil},
+		{7, int64(7)},
+		{uint32(7), int64(7)},
+		{int64(math.MinInt64), int64(math.MinInt64)},
+		{float32(1.5), 1.5},
+		{true, true},
+		{"héllo", "héllo"},
+		{at, at},
+		{[]byte{0, 255}, []byte{0, 255}},
+		{json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":1}`)},
+	}
+	for _, tt := range tests {
+		encoded, err := encodeValue(tt.in)
+		if err != nil {
+			t.Fatalf("encode %v: %v", tt.in, err)
+		}
+		got, err := decodeValue(encoded)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%#v came back as %#v, %v", tt.in, got, err)
+		}
+	}
+	for _, bad := range []any{uint64(math.MaxUint64), struct{}{}} {
+		if _, err := encodeValue(bad); err == nil {
+			t.Errorf("encoding %#v succeeded", bad)
+		}
+	}
+}
+
+// serve starts a server for db on a local port and returns its address.
+func serve(t *testing.T, db *sqldb.Database) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(db)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, address string) *Client {
+	t.Helper()
+	c, err := Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientServerRoundTrip(t *testing.T) {
+	c := dial(t, serve(t, sqldb.NewDatabase()))
+	if _, err := c.Exec("CREATE TABLE events (id INT PRIMARY KEY, at TIMESTAMP, data BYTES)"); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	res, err := c.Exec("INSERT INTO events (id, at, data) VALUES (?, ?, ?), (2, NULL, NULL)", 1, at, []byte("hi"))
+	if err != nil || res.RowsAffected != 2 {
+		t.Fatalf("insert = %+v, %v", res, err)
+	}
+	rs, err := c.Query("SELECT id, at, data FROM events WHERE id >= ? ORDER BY id", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"id": int64(1), "at": at, "data": []byte("hi")},
+		{"id": int64(2), "at": nil, "data": nil},
+	}
+	if !reflect.DeepEqual(rs.Columns, []string{"id", "at", "data"}) || !reflect.DeepEqual(rs.Rows, want) {
+		t.Fatalf("query = %v %v, want %v", rs.Columns, rs.Rows, want)
+	}
+	// errors come back as errors and leave the session usable
+	if _, err := c.Exec("INSERT INTO events (id) VALUES (1)"); err == nil {
+		t.Fatal("duplicate insert succeeded")
+	}
+	if _, err := c.Query("SELECT * FROM nope"); !errors.Is(err, sqldb.ErrTableNotFound) {
+		t.Fatalf("query of a missing table = %v, want ErrTableNotFound", err)
+	}
+	if err := c.Commit(); err == nil {
+		t.Fatal("commit without a transaction succeeded")
+	}
+	if _, err := c.Query("SELECT id FROM events"); err != nil {
+		t.Fatalf("session broken after errors: %v", err)
+	}
+}
+
+func TestSessionTransactions(t *testing.T) {
+	db := sqldb.NewDatabase()
+	address := serve(t, db)
+	first, second := dial(t, address), dial(t, address)
+	if _, err := first.Exec("CREATE TABLE users (id INT PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Begin(); err == nil {
+		t.Fatal("nested Begin succeeded")
+	}
+	if _, err := first.Exec("INSERT INTO users (id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	count := func(c *Client) int {
+		t.Helper()
+		rs, err := c.Query("SELECT id FROM users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rs.Rows)
+	}
+	if n, m := count(first), count(second); n != 1 || m != 0 {
+		t.Fatalf("before commit the sessions see %d and %d rows, want 1 and 0", n, m)
+	}
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(second); n != 1 {
+		t.Fatalf("after commit the other session sees %d rows, want 1", n)
+	}
+
+	// a transaction left open by a closed connection is never committed
+	if err := second.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Exec("INSERT INTO users (id) VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	second.Close()
+	if _, err := second.Query("SELECT id FROM users"); err == nil {
+		t.Fatal("query on a closed client succeeded")
+	}
+	if n := count(first); n != 1 {
+		t.Fatalf("after the session closed %d rows are visible, want 1", n)
+	}
+}
+
+func TestErrorsKeepTheirTypes(t *testing.T) {
+	c := dial(t, serve(t, sqldb.NewDatabase()))
+	if _, err := c.Exec("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(3) NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Query("SELECT nope FROM users"); !errors.Is(err, sqldb.ErrUnknownColumn) {
+		t.Fatalf("unknown column = %v, want ErrUnknownColumn", err)
+	}
+	_, err := c.Exec("INSERT INTO users (id, name) VALUES ('x', 'linus')")
+	var wireErr *Error
+	if !errors.As(err, &wireErr) || len(wireErr.Unwrap()) != 2 {
+		t.Fatalf("insert = %#v, want an *Error with both violations", err)
+	}
+	var violation *sqldb.ConstraintViolation
+	if !errors.As(err, &violation) || violation.Table != "users" || violation.Column != "id" ||
+		violation.Constraint != sqldb.ConstraintType || violation.Value != "x" {
+		t.Fatalf("first violation = %+v", violation)
+	}
+	if err.Error() != "users.id violates type constraint: expected int but got string\n"+
+		"users.name violates max length constraint: length 5 exceeds max length 3" {
+		t.Fatalf("message = %q", err.Error())
+	}
+}
+
+func TestOversizedMessages(t *testing.T) {
+	defer func(limit int) { maxFrame = limit }(maxFrame)
+	maxFrame = 1 << 10
+	c := dial(t, serve(t, sqldb.NewDatabase()))
+	if _, err := c.Exec("CREATE TABLE notes (id INT PRIMARY KEY, body STRING)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if _, err := c.Exec("INSERT INTO notes (id, body) VALUES (?, ?)", i, strings.Repeat("x", 200)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Query("SELECT * FROM notes"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized result = %v, want ErrTooLarge", err)
+	}
+	if _, err := c.Exec("INSERT INTO notes (id, body) VALUES (?, ?)", 10, strings.Repeat("x", 2000)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized request = %v, want ErrTooLarge", err)
+	}
+	// neither message was sent, so the session goes on
+	if rs, err := c.Query("SELECT id FROM notes WHERE id < 3"); err != nil || len(rs.Rows) != 3 {
+		t.Fatalf("query after oversized messages = %v, %v", rs, err)
+	}
+}